@@ -1,5 +1,5 @@
 terraform {
-  required_version = ">= 0.13"
+  required_version = ">= 1.0"
 
   required_providers {
     mongodb = {
@@ -14,7 +14,6 @@ provider "mongodb" {
   port = "27017"
   username = "root"
   password = "root"
-  ssl = false
   auth_database = "admin"
 }
 
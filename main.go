@@ -1,12 +1,46 @@
 package main
 
 import (
+	"context"
 	"github.com/Kaginari/terraform-provider-mongodb/mongodb"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6/tf6server"
+	"github.com/hashicorp/terraform-plugin-mux/tf5to6server"
+	"github.com/hashicorp/terraform-plugin-mux/tf6muxserver"
+	"log"
 )
 
+// set by goreleaser
+var version = "dev"
+
 func main() {
-	plugin.Serve(&plugin.ServeOpts{
-		ProviderFunc: mongodb.Provider,
-	})
+	ctx := context.Background()
+	shutdownTracing, err := mongodb.SetupTracing(ctx, version)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sdkProvider := mongodb.Provider(version)
+	/* the sdk provider speaks protocol 5, it is upgraded to be muxed with the framework provider */
+	upgradedSdkServer, err := tf5to6server.UpgradeServer(ctx, sdkProvider.GRPCProvider)
+	if err != nil {
+		log.Fatal(err)
+	}
+	muxServer, err := tf6muxserver.NewMuxServer(ctx,
+		func() tfprotov6.ProviderServer {
+			return upgradedSdkServer
+		},
+		providerserver.NewProtocol6(mongodb.NewFrameworkProvider(version, sdkProvider)),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = tf6server.Serve("registry.terraform.io/Kaginari/mongodb", muxServer.ProviderServer)
+	mongodb.DisconnectClients(ctx)
+	if shutdownErr := shutdownTracing(ctx); shutdownErr != nil {
+		log.Print(shutdownErr)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,14 +1,35 @@
 package mongodb
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/youmark/pkcs8"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/tag"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"software.sslmate.com/src/go-pkcs12"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 
@@ -22,11 +43,254 @@ type ClientConfig struct {
 	InsecureSkipVerify bool
 	ReplicaSet string
 	Certificate	    string
+	Srv      bool
+	ConnectionURI string
+	Hosts    []string
+	AuthMechanism string
+	ClientCertificate string
+	ClientKey string
+	AwsSessionToken string
+	GssapiServiceName string
+	GssapiServiceRealm string
+	GssapiCanonicalizeHostName bool
+	OidcTokenFile string
+	OidcEnvironment string
+	OidcTokenResource string
+	ProxyURL string
+	ConnectTimeoutMS int
+	ServerSelectionTimeoutMS int
+	SocketTimeoutMS int
+	MaxConnIdleTimeMS int
+	ReadPreference *ReadPreference
+	WriteConcern *WriteConcern
+	ReadConcern string
+	Compressors []string
+	ZlibLevel int
+	ZstdLevel int
+	AppName string
+	DirectConnection bool
+	RetryReads *bool // nil keeps the driver default
+	RetryWrites *bool // nil keeps the driver default
+	AllowInvalidHostnames bool
+	CaFile string
+	CertFile string
+	KeyFile string
+	KeyPassword string
+	Pkcs12File string
+	Pkcs12Password string
+	DisableOCSPEndpointCheck bool
+	CrlFile string
+	SocketPath string
+	SSH *SSHConfig
+	AuthSource string
+	HeartbeatFrequencyMS int
+	ResolverAddress string
+	HostOverrides map[string]string
+	DocDBCompatibility bool
+	CosmosDBCompatibility bool
+	MaxRetries int
+	RetryDelay time.Duration
+	MaxPoolSize int
+	WaitForConnection time.Duration
+	ConnectionRetryInterval time.Duration
+	FipsMode bool
+	PasswordPolicy *PasswordPolicy
+}
+
+/*
+the meta of the resources, the config tells them which compatibility mode
+the provider runs in
+*/
+type ProviderMeta struct {
+	Client *mongo.Client
+	Config *ClientConfig
+}
+
+var errUnknownConfig = errors.New("the provider configuration is unknown, it depends on values known after apply")
+
+/*
+the operation is retried max_retries times on transient errors, waiting
+retry_delay between the attempts
+*/
+func (m *ProviderMeta) retry(ctx context.Context, operation func() error) error {
+	if m == nil || m.Client == nil {
+		return errUnknownConfig
+	}
+	err := operation()
+	for attempt := 0; attempt < m.Config.MaxRetries && err != nil && isTransientError(err); attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(m.Config.RetryDelay):
+		}
+		err = operation()
+	}
+	return err
+}
+
+/*
+network errors, timeouts, errors labelled retryable by the server and the
+codes it returns during elections and shutdowns
+*/
+var transientErrorCodes = []int{6, 7, 89, 91, 189, 262, 9001, 10107, 11600, 11602, 13435, 13436}
+
+func isTransientError(err error) bool {
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return true
+	}
+	var serverError mongo.ServerError
+	if !errors.As(err, &serverError) {
+		return false
+	}
+	if serverError.HasErrorLabel("RetryableWriteError") || serverError.HasErrorLabel("TransientTransactionError") {
+		return true
+	}
+	for _, code := range transientErrorCodes {
+		if serverError.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+the provider configurations with the same connection settings share one
+client, the clients are disconnected when terraform stops the provider,
+their topology goroutines and connections would leak otherwise
+*/
+var clients struct {
+	sync.Mutex
+	connected map[string]*mongo.Client
+}
+
+/*
+the key ignores the settings which do not change the client and the order
+of the seed list
+*/
+func (c *ClientConfig) poolKey() (string, error) {
+	normalized := *c
+	normalized.Host = strings.ToLower(c.Host)
+	normalized.Hosts = nil
+	for _, host := range c.Hosts {
+		normalized.Hosts = append(normalized.Hosts, strings.ToLower(host))
+	}
+	sort.Strings(normalized.Hosts)
+	normalized.MaxRetries = 0
+	normalized.RetryDelay = 0
+	normalized.WaitForConnection = 0
+	normalized.ConnectionRetryInterval = 0
+	normalized.DocDBCompatibility = false
+	normalized.CosmosDBCompatibility = false
+	normalized.PasswordPolicy = nil
+	document, err := json.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(document)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func pooledClient(key string) (*mongo.Client, bool) {
+	clients.Lock()
+	defer clients.Unlock()
+	client, ok := clients.connected[key]
+	return client, ok
+}
+
+/*
+when another configuration connected the same client meanwhile, the new one
+is disconnected and the pooled one is returned
+*/
+func registerClient(ctx context.Context, key string, client *mongo.Client) *mongo.Client {
+	clients.Lock()
+	defer clients.Unlock()
+	if pooled, ok := clients.connected[key]; ok {
+		_ = client.Disconnect(ctx)
+		return pooled
+	}
+	if clients.connected == nil {
+		clients.connected = map[string]*mongo.Client{}
+	}
+	clients.connected[key] = client
+	return client
+}
+
+func DisconnectClients(ctx context.Context) {
+	clients.Lock()
+	defer clients.Unlock()
+	for _, client := range clients.connected {
+		if err := client.Disconnect(ctx); err != nil {
+			log.Printf("[WARN] could not disconnect from mongodb : %s", err)
+		}
+	}
+	clients.connected = nil
+	closeSSHTunnels()
+}
+
+type ReadPreference struct {
+	Mode                string
+	TagSets             []map[string]string
+	MaxStalenessSeconds int
+}
+
+type WriteConcern struct {
+	W          string
+	J          bool
+	WTimeoutMS int
+}
+
+/*
+w is either a number of members, majority or a custom tag set name
+*/
+func (w *WriteConcern) writeConcern() *writeconcern.WriteConcern {
+	writeConcern := &writeconcern.WriteConcern{}
+	if w.W != "" {
+		if members, err := strconv.Atoi(w.W); err == nil {
+			writeConcern.W = members
+		} else {
+			writeConcern.W = w.W
+		}
+	}
+	if w.J {
+		journal := true
+		writeConcern.Journal = &journal
+	}
+	if w.WTimeoutMS > 0 {
+		writeConcern.WTimeout = time.Duration(w.WTimeoutMS) * time.Millisecond
+	}
+	return writeConcern
+}
 
+func (r *ReadPreference) readPref() (*readpref.ReadPref, error) {
+	mode, err := readpref.ModeFromString(r.Mode)
+	if err != nil {
+		return nil, err
+	}
+	var readPrefOptions []readpref.Option
+	if len(r.TagSets) != 0 {
+		readPrefOptions = append(readPrefOptions, readpref.WithTagSets(tag.NewTagSetsFromMaps(r.TagSets)...))
+	}
+	if r.MaxStalenessSeconds > 0 {
+		readPrefOptions = append(readPrefOptions, readpref.WithMaxStaleness(time.Duration(r.MaxStalenessSeconds)*time.Second))
+	}
+	return readpref.New(mode, readPrefOptions...)
 }
 type DbUser struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
+	CustomData map[string]interface{} `json:"custom_data"`
+	AuthenticationRestrictions []AuthenticationRestriction `json:"authentication_restriction"`
+	Mechanisms []string `json:"mechanisms"`
+	PasswordDigested bool `json:"password_digested"`
+}
+
+/*
+the client ip addresses and the server addresses a user can authenticate
+from and to, as cidr ranges or ip addresses
+*/
+type AuthenticationRestriction struct {
+	ClientSource  []string `json:"client_source" bson:"clientSource,omitempty"`
+	ServerAddress []string `json:"server_address" bson:"serverAddress,omitempty"`
 }
 
 type Role struct {
@@ -39,6 +303,8 @@ func (role Role) String() string {
 type PrivilegeDto struct {
 	Db         string `json:"db"`
 	Collection string `json:"collection"`
+	Cluster    bool   `json:"cluster"`
+	SystemBuckets string `json:"system_buckets" mapstructure:"system_buckets"`
 	Actions  []string `json:"actions"`
 }
 
@@ -55,69 +321,631 @@ type SingleResultGetUser struct {
 			Role string `json:"role"`
 			Db   string `json:"db"`
 		} `json:"roles"`
+		CustomData map[string]interface{} `json:"customData" bson:"customData"`
+		AuthenticationRestrictions []AuthenticationRestriction `json:"authenticationRestrictions" bson:"authenticationRestrictions"`
+		Mechanisms []string `json:"mechanisms"`
 	} `json:"users"`
 }
 type SingleResultGetRole struct {
 	Roles []struct {
 		Role      string `json:"role"`
 		Db        string `json:"db"`
+		IsBuiltin bool   `json:"isBuiltin" bson:"isBuiltin"`
+		Roles     []Role `json:"roles"`
 		InheritedRoles []struct {
 			Role string `json:"role"`
 			Db   string `json:"db"`
 		} `json:"inheritedRoles"`
-		Privileges []struct {
-			Resource struct {
-				Db         string `json:"db"`
-				Collection string `json:"collection"`
-			} `json:"resource"`
-			Actions []string `json:"actions"`
-		} `json:"privileges"`
+		Privileges []RolePrivilege `json:"privileges"`
 	} `json:"roles"`
 }
-func addArgs(arguments string,newArg string) string {
-	if arguments != "" {
-		return arguments+"&"+newArg
-	} else {
-		return "/?"+newArg
+
+type RolePrivilege struct {
+	Resource struct {
+		Db            string `json:"db"`
+		Collection    string `json:"collection"`
+		Cluster       bool   `json:"cluster"`
+		SystemBuckets string `json:"system_buckets" bson:"system_buckets"`
+	} `json:"resource"`
+	Actions []string `json:"actions"`
+}
+/*
+with srv the driver resolves the seed list and the connection options
+from the SRV and TXT records of the host, a port is not allowed.
+url.URL escapes the hosts (a unix socket path becomes %2Ftmp%2F...)
+and url.Values the options
+*/
+func (c *ClientConfig) uri() string {
+	if c.ConnectionURI != "" {
+		return c.ConnectionURI
+	}
+	arguments := url.Values{}
+	if c.Ssl {
+		arguments.Set("tls", "true")
+		if c.InsecureSkipVerify {
+			arguments.Set("tlsInsecure", "true")
+		}
+	}
+	if c.ReplicaSet != "" {
+		arguments.Set("replicaSet", c.ReplicaSet)
 	}
+	if c.Srv {
+		return connectionString("mongodb+srv", c.Host, nil, arguments)
+	}
+	return connectionString("mongodb", c.seedList(), nil, arguments)
+}
 
+/*
+url.URL escapes the credentials and the options, the connection_string
+function builds its uri here too
+*/
+func connectionString(scheme string, hosts string, user *url.Userinfo, arguments url.Values) string {
+	uri := url.URL{
+		Scheme:   scheme,
+		User:     user,
+		Host:     hosts,
+		Path:     "/",
+		RawQuery: arguments.Encode(),
+	}
+	return uri.String()
 }
 
-func (c *ClientConfig) MongoClient() (*mongo.Client, error) {
+/*
+a host which already has a port keeps it
+*/
+func (c *ClientConfig) hostWithPort(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, c.Port)
+}
 
+/*
+a unix socket path has no port
+*/
+func (c *ClientConfig) seedList() string {
+	if c.SocketPath != "" {
+		return c.SocketPath
+	}
+	if len(c.Hosts) == 0 {
+		return c.hostWithPort(c.Host)
+	}
+	var hosts []string
+	for _, host := range c.Hosts {
+		hosts = append(hosts, c.hostWithPort(host))
+	}
+	return strings.Join(hosts, ",")
+}
 
-	var arguments = ""
-	if c.Ssl {
-		arguments = addArgs(arguments,"ssl=true")
+/*
+without a username nor an auth mechanism the provider does not authenticate,
+e.g. to create the first user through the localhost exception, the
+credentials embedded in connection_uri are kept unless username is set
+*/
+func (c *ClientConfig) clientOptions() *options.ClientOptions {
+	clientOptions := options.Client().ApplyURI(c.uri())
+	if c.Username == "" && (c.ConnectionURI != "" || c.AuthMechanism == "") {
+		return clientOptions
 	}
-	if c.ReplicaSet != "" {
-		arguments = addArgs(arguments,"replicaSet="+c.ReplicaSet)
+	clientOptions.SetAuth(c.credential())
+	return clientOptions
+}
+
+/*
+auth_source takes precedence over auth_database which was used for it before
+*/
+func (c *ClientConfig) authSource() string {
+	if c.AuthSource != "" {
+		return c.AuthSource
+	}
+	return c.DB
+}
+
+/*
+an empty auth mechanism lets the driver negotiate it with the server,
+x509 users live in $external and are identified by the client certificate,
+aws uses the access key as username and the secret key as password or
+falls back to the environment and the instance role when they are empty,
+gssapi needs a provider built with the gssapi tag and a kerberos principal
+as username, the password is optional when a keytab or a ticket cache is used,
+oidc reads the token from a file on every authentication so rotated tokens
+(kubernetes projected service account, azure workload identity) are picked up
+or lets the driver fetch it from the azure or gcp metadata endpoint
+*/
+func (c *ClientConfig) credential() options.Credential {
+	switch c.AuthMechanism {
+	case "MONGODB-X509":
+		return options.Credential{
+			AuthMechanism: c.AuthMechanism,
+			AuthSource:    "$external",
+			Username:      c.Username,
+		}
+	case "MONGODB-AWS":
+		credential := options.Credential{
+			AuthMechanism: c.AuthMechanism,
+			AuthSource:    "$external",
+			Username:      c.Username,
+			Password:      c.Password,
+		}
+		if c.AwsSessionToken != "" {
+			credential.AuthMechanismProperties = map[string]string{"AWS_SESSION_TOKEN": c.AwsSessionToken}
+		}
+		return credential
+	case "GSSAPI":
+		credential := options.Credential{
+			AuthMechanism: c.AuthMechanism,
+			AuthSource:    "$external",
+			Username:      c.Username,
+			Password:      c.Password,
+			PasswordSet:   c.Password != "",
+			AuthMechanismProperties: map[string]string{},
+		}
+		if c.GssapiServiceName != "" {
+			credential.AuthMechanismProperties["SERVICE_NAME"] = c.GssapiServiceName
+		}
+		if c.GssapiServiceRealm != "" {
+			credential.AuthMechanismProperties["SERVICE_REALM"] = c.GssapiServiceRealm
+		}
+		if c.GssapiCanonicalizeHostName {
+			credential.AuthMechanismProperties["CANONICALIZE_HOST_NAME"] = "true"
+		}
+		return credential
+	case "MONGODB-OIDC":
+		credential := options.Credential{
+			AuthMechanism: c.AuthMechanism,
+			AuthSource:    "$external",
+			Username:      c.Username,
+		}
+		if c.OidcTokenFile != "" {
+			credential.OIDCMachineCallback = oidcTokenFileCallback(c.OidcTokenFile)
+		}
+		if c.OidcEnvironment != "" {
+			credential.AuthMechanismProperties = map[string]string{
+				"ENVIRONMENT":    c.OidcEnvironment,
+				"TOKEN_RESOURCE": c.OidcTokenResource,
+			}
+		}
+		return credential
+	}
+	return options.Credential{
+		AuthMechanism: c.AuthMechanism,
+		AuthSource:    c.authSource(),
+		Username:      c.Username,
+		Password:      c.Password,
+	}
+}
+
+func (c *ClientConfig) MongoClient() (*mongo.Client, error) {
+
+	var clientOptions = c.clientOptions()
+	clientOptions.SetMonitor((&commandTracer{}).monitor())
+	if c.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(uint64(c.MaxPoolSize))
 	}
-	var uri = "mongodb://" + c.Host + ":" + c.Port + arguments
 
 	/*
 	@Since: v0.0.7
 	add certificate support for documentDB
 	 */
-	if c.Certificate != "" {
-		tlsConfig, err := getTLSConfigWithAllServerCertificates([]byte(c.Certificate))
+	if c.hasTLSConfig() {
+		tlsConfig, err := c.tlsConfig()
 		if err != nil {
 			return nil, err
 		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
 
-		mongoClient, err := mongo.NewClient(options.Client().ApplyURI(uri).SetAuth(options.Credential{
-			AuthSource: c.DB, Username: c.Username, Password: c.Password,
-		}).SetTLSConfig(tlsConfig))
-
-		return mongoClient, err
+	dialer, err := c.dialer()
+	if err != nil {
+		return nil, err
+	}
+	if dialer != nil {
+		clientOptions.SetDialer(dialer)
+	}
+	if c.ConnectTimeoutMS > 0 {
+		clientOptions.SetConnectTimeout(time.Duration(c.ConnectTimeoutMS) * time.Millisecond)
+	}
+	if c.ServerSelectionTimeoutMS > 0 {
+		clientOptions.SetServerSelectionTimeout(time.Duration(c.ServerSelectionTimeoutMS) * time.Millisecond)
+	}
+	if c.SocketTimeoutMS > 0 {
+		clientOptions.SetSocketTimeout(time.Duration(c.SocketTimeoutMS) * time.Millisecond)
+	}
+	if c.MaxConnIdleTimeMS > 0 {
+		clientOptions.SetMaxConnIdleTime(time.Duration(c.MaxConnIdleTimeMS) * time.Millisecond)
+	}
+	if c.HeartbeatFrequencyMS > 0 {
+		clientOptions.SetHeartbeatInterval(time.Duration(c.HeartbeatFrequencyMS) * time.Millisecond)
+	}
+	if c.ReadPreference != nil {
+		readPreference, err := c.ReadPreference.readPref()
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetReadPreference(readPreference)
+	}
+	if c.WriteConcern != nil {
+		clientOptions.SetWriteConcern(c.WriteConcern.writeConcern())
+	}
+	if c.ReadConcern != "" {
+		clientOptions.SetReadConcern(&readconcern.ReadConcern{Level: c.ReadConcern})
+	}
+	if len(c.Compressors) != 0 {
+		clientOptions.SetCompressors(c.Compressors)
+		clientOptions.SetZlibLevel(c.ZlibLevel)
+		clientOptions.SetZstdLevel(c.ZstdLevel)
+	}
+	if c.AppName != "" {
+		clientOptions.SetAppName(c.AppName)
+	}
+	if c.DirectConnection {
+		clientOptions.SetDirect(true)
+	}
+	if c.RetryReads != nil {
+		clientOptions.SetRetryReads(*c.RetryReads)
+	}
+	if c.RetryWrites != nil {
+		clientOptions.SetRetryWrites(*c.RetryWrites)
+	}
+	if c.DisableOCSPEndpointCheck {
+		clientOptions.SetDisableOCSPEndpointCheck(true)
 	}
 
-	client, err := mongo.NewClient(options.Client().ApplyURI(uri).SetAuth(options.Credential{
-		AuthSource: c.DB, Username: c.Username, Password: c.Password,
-	}))
+	client, err := mongo.NewClient(clientOptions)
 	return client, err
 }
 
+/*
+the password of a user is verified by authenticating with it on a client
+of one connection, false is returned when the server refuses it
+*/
+func (c *ClientConfig) verifyCredentials(ctx context.Context, username string, password string, database string) (bool, error) {
+	config := *c
+	config.Username = username
+	config.Password = password
+	config.AuthSource = database
+	config.AuthMechanism = ""
+	config.AwsSessionToken = ""
+	config.MaxPoolSize = 1
+	client, err := config.MongoClient()
+	if err != nil {
+		return false, err
+	}
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := client.Connect(connectCtx); err != nil {
+		return false, err
+	}
+	defer client.Disconnect(context.Background())
+	err = client.Ping(connectCtx, nil)
+	if err == nil {
+		return true, nil
+	}
+	if isAuthenticationError(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+/*
+nil keeps the dialer of the driver, host overrides are applied before
+the address reaches the proxy or the ssh tunnel
+*/
+func (c *ClientConfig) dialer() (options.ContextDialer, error) {
+	var dialer options.ContextDialer
+	if c.ResolverAddress != "" {
+		dialer = resolverDialer(c.ResolverAddress)
+	}
+	if c.ProxyURL != "" {
+		var err error
+		if dialer, err = proxyDialer(c.ProxyURL); err != nil {
+			return nil, err
+		}
+	}
+	if c.SSH != nil {
+		dialer = &sshDialer{config: c.SSH}
+	}
+	if len(c.HostOverrides) != 0 {
+		if dialer == nil {
+			dialer = &net.Dialer{}
+		}
+		dialer = &hostOverridesDialer{overrides: c.HostOverrides, forward: dialer}
+	}
+	return dialer, nil
+}
+
+/*
+documentdb only accepts tls connections verified with the rds ca bundle and
+does not support retryable writes
+*/
+func (c *ClientConfig) applyDocDBCompatibility() error {
+	c.Ssl = true
+	if c.RetryWrites == nil {
+		retryWrites := false
+		c.RetryWrites = &retryWrites
+	}
+	if c.Certificate == "" && c.CaFile == "" && !c.InsecureSkipVerify {
+		return errors.New("docdb_compatibility requires the RDS CA bundle in certificate or ca_file, it can be downloaded from https://truststore.pki.rds.amazonaws.com/global/global-bundle.pem")
+	}
+	return nil
+}
+
+/*
+cosmos db only accepts tls connections, does not support retryable writes
+and its request unit accounts do not negotiate scram-sha-256
+*/
+func (c *ClientConfig) applyCosmosDBCompatibility() {
+	c.Ssl = true
+	if c.RetryWrites == nil {
+		retryWrites := false
+		c.RetryWrites = &retryWrites
+	}
+	if c.AuthMechanism == "" && c.Username != "" {
+		c.AuthMechanism = "SCRAM-SHA-1"
+	}
+}
+
+func (c *ClientConfig) hasTLSConfig() bool {
+	return c.FipsMode || c.Certificate != "" || c.ClientCertificate != "" || c.CaFile != "" || c.CertFile != "" || c.Pkcs12File != "" ||
+		(c.Ssl && (c.InsecureSkipVerify || c.AllowInvalidHostnames || c.CrlFile != ""))
+}
+
+/*
+the *_file attributes take precedence over the PEM contents
+*/
+func (c *ClientConfig) tlsMaterial() (ca, cert, key []byte, err error) {
+	ca, cert, key = []byte(c.Certificate), []byte(c.ClientCertificate), []byte(c.ClientKey)
+	if c.CaFile != "" {
+		if ca, err = os.ReadFile(c.CaFile); err != nil {
+			return nil, nil, nil, fmt.Errorf("could not read ca_file : %s", err)
+		}
+	}
+	if c.CertFile != "" {
+		if cert, err = os.ReadFile(c.CertFile); err != nil {
+			return nil, nil, nil, fmt.Errorf("could not read cert_file : %s", err)
+		}
+	}
+	if c.KeyFile != "" {
+		if key, err = os.ReadFile(c.KeyFile); err != nil {
+			return nil, nil, nil, fmt.Errorf("could not read key_file : %s", err)
+		}
+	}
+	return ca, cert, key, nil
+}
+
+func (c *ClientConfig) tlsConfig() (*tls.Config, error) {
+	ca, cert, key, err := c.tlsMaterial()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := new(tls.Config)
+	if len(ca) != 0 {
+		tlsConfig, err = getTLSConfigWithAllServerCertificates(ca)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(cert) != 0 {
+		if c.KeyPassword != "" {
+			if key, err = decryptPrivateKey(key, c.KeyPassword); err != nil {
+				return nil, err
+			}
+		}
+		tlsCert, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{tlsCert}
+	}
+	if c.Pkcs12File != "" {
+		tlsCert, caCertificates, err := loadPkcs12(c.Pkcs12File, c.Pkcs12Password)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{tlsCert}
+		// the CA chain of the bundle is only trusted when no CA is given
+		if len(ca) == 0 && len(caCertificates) != 0 {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			for _, caCertificate := range caCertificates {
+				tlsConfig.RootCAs.AddCert(caCertificate)
+			}
+		}
+	}
+	if c.InsecureSkipVerify {
+		tlsConfig.InsecureSkipVerify = true
+	} else if c.AllowInvalidHostnames {
+		/* the default verification can not skip the hostname alone,
+		   it is disabled and replaced by a verification of the chain only */
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifyChainWithoutHostname(tlsConfig.RootCAs)
+	}
+	if c.CrlFile != "" {
+		crl, err := os.ReadFile(c.CrlFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read crl_file : %s", err)
+		}
+		verifyConnection, err := verifyNotRevoked(crl)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.VerifyConnection = verifyConnection
+	}
+	if c.FipsMode {
+		tlsConfig.MinVersion = tls.VersionTLS12
+		tlsConfig.CipherSuites = fipsCipherSuites
+		tlsConfig.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+	}
+	return tlsConfig, nil
+}
+
+/*
+the tls 1.2 suites approved by SP 800-52, the tls 1.3 suites can not be
+configured and are restricted by the go fips 140 mode
+*/
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+/*
+fips mode forces tls and refuses to skip the verification of the server
+certificate
+*/
+func (c *ClientConfig) applyFipsMode() error {
+	if c.InsecureSkipVerify {
+		return errors.New("fips_mode can not be used with insecure_skip_verify or tls.insecure")
+	}
+	if c.ConnectionURI != "" {
+		if uri, err := url.Parse(c.ConnectionURI); err == nil {
+			query := uri.Query()
+			for _, option := range []string{"tlsInsecure", "tlsAllowInvalidCertificates", "sslAllowInvalidCertificates"} {
+				if strings.EqualFold(query.Get(option), "true") {
+					return fmt.Errorf("fips_mode can not be used with %s in connection_uri", option)
+				}
+			}
+		}
+	}
+	c.Ssl = true
+	return nil
+}
+
+/*
+the crl file holds one or more PEM or a single DER revocation list, a peer
+certificate is rejected when a list of its issuer contains its serial number
+*/
+func verifyNotRevoked(crl []byte) (func(tls.ConnectionState) error, error) {
+	var revocationLists []*x509.RevocationList
+	for rest := crl; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		revocationList, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse crl_file : %s", err)
+		}
+		revocationLists = append(revocationLists, revocationList)
+	}
+	if len(revocationLists) == 0 {
+		revocationList, err := x509.ParseRevocationList(crl)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse crl_file : %s", err)
+		}
+		revocationLists = append(revocationLists, revocationList)
+	}
+	return func(state tls.ConnectionState) error {
+		for _, certificate := range state.PeerCertificates {
+			for _, revocationList := range revocationLists {
+				if !bytes.Equal(revocationList.RawIssuer, certificate.RawIssuer) {
+					continue
+				}
+				for _, revoked := range revocationList.RevokedCertificates {
+					if revoked.SerialNumber.Cmp(certificate.SerialNumber) == 0 {
+						return fmt.Errorf("the certificate %s has been revoked", certificate.Subject)
+					}
+				}
+			}
+		}
+		return nil
+	}, nil
+}
+
+func loadPkcs12(path string, password string) (tls.Certificate, []*x509.Certificate, error) {
+	pfxData, err := os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("could not read pkcs12_file : %s", err)
+	}
+	privateKey, certificate, caCertificates, err := pkcs12.DecodeChain(pfxData, password)
+	if err != nil {
+		return tls.Certificate{}, nil, fmt.Errorf("could not decode pkcs12_file : %s", err)
+	}
+	tlsCert := tls.Certificate{
+		Certificate: [][]byte{certificate.Raw},
+		PrivateKey:  privateKey,
+		Leaf:        certificate,
+	}
+	return tlsCert, caCertificates, nil
+}
+
+/*
+encrypted PKCS#8 keys and legacy encrypted PEM keys (Proc-Type: 4,ENCRYPTED)
+are decrypted, other blocks are kept as they are
+*/
+func decryptPrivateKey(keyPEMBlock []byte, password string) ([]byte, error) {
+	var decrypted []byte
+	for {
+		var block *pem.Block
+		block, keyPEMBlock = pem.Decode(keyPEMBlock)
+		if block == nil {
+			break
+		}
+		switch {
+		case block.Type == "ENCRYPTED PRIVATE KEY":
+			privateKey, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(password))
+			if err != nil {
+				return nil, fmt.Errorf("could not decrypt the private key : %s", err)
+			}
+			der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+			if err != nil {
+				return nil, err
+			}
+			block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+		case x509.IsEncryptedPEMBlock(block):
+			der, err := x509.DecryptPEMBlock(block, []byte(password))
+			if err != nil {
+				return nil, fmt.Errorf("could not decrypt the private key : %s", err)
+			}
+			block = &pem.Block{Type: block.Type, Bytes: der}
+		}
+		decrypted = append(decrypted, pem.EncodeToMemory(block)...)
+	}
+	if len(decrypted) == 0 {
+		return nil, errors.New("Failed parsing the private key pem")
+	}
+	return decrypted, nil
+}
+
+/*
+nil roots means the system pool
+*/
+func verifyChainWithoutHostname(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("the server did not present a certificate")
+		}
+		var certificates []*x509.Certificate
+		for _, rawCert := range rawCerts {
+			certificate, err := x509.ParseCertificate(rawCert)
+			if err != nil {
+				return err
+			}
+			certificates = append(certificates, certificate)
+		}
+		intermediates := x509.NewCertPool()
+		for _, certificate := range certificates[1:] {
+			intermediates.AddCert(certificate)
+		}
+		_, err := certificates[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
+
+func oidcTokenFileCallback(path string) options.OIDCCallback {
+	return func(ctx context.Context, args *options.OIDCArgs) (*options.OIDCCredential, error) {
+		token, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the OIDC token file : %s", err)
+		}
+		return &options.OIDCCredential{AccessToken: strings.TrimSpace(string(token))}, nil
+	}
+}
+
 func getTLSConfigWithAllServerCertificates(ca []byte) (*tls.Config, error) {
 	/* As of version 1.2.1, the MongoDB Go Driver will only use the first CA server certificate found in sslcertificateauthorityfile.
 	   The code below addresses this limitation by manually appending all server certificates found in sslcertificateauthorityfile
@@ -155,18 +983,7 @@ func buildHttpClientFromCertPath(ca , cert , key []byte, config *ClientConfig) (
 		}
 		tlsConfig.RootCAs = caPool
 	}
-	var arguments = ""
-	if config.Ssl {
-		arguments = addArgs(arguments,"ssl=true")
-	}
-	if config.ReplicaSet != "" {
-		arguments = addArgs(arguments,"replicaSet="+config.ReplicaSet)
-	}
-	var uri = "mongodb://" + config.Host + ":" + config.Port + arguments
-
-	client, err := mongo.NewClient(options.Client().ApplyURI(uri).SetAuth(options.Credential{
-		AuthSource: config.DB, Username: config.Username , Password: config.Password,
-	}).SetTLSConfig(tlsConfig))
+	client, err := mongo.NewClient(config.clientOptions().SetTLSConfig(tlsConfig))
 
 	return client , err
 
@@ -174,6 +991,12 @@ func buildHttpClientFromCertPath(ca , cert , key []byte, config *ClientConfig) (
 func buildHTTPClientFromBytes(caPEMCert, certPEMBlock, keyPEMBlock []byte, config *ClientConfig) (*mongo.Client, error) {
 	tlsConfig := &tls.Config{}
 	if certPEMBlock != nil && keyPEMBlock != nil {
+		if config.KeyPassword != "" {
+			var err error
+			if keyPEMBlock, err = decryptPrivateKey(keyPEMBlock, config.KeyPassword); err != nil {
+				return nil, err
+			}
+		}
 		tlsCert, err := tls.X509KeyPair(certPEMBlock, keyPEMBlock)
 		if err != nil {
 			return nil, err
@@ -193,18 +1016,7 @@ func buildHTTPClientFromBytes(caPEMCert, certPEMBlock, keyPEMBlock []byte, confi
 	if config.InsecureSkipVerify {
 		tlsConfig.InsecureSkipVerify = true
 	}
-	var arguments = ""
-	if config.Ssl {
-		arguments = addArgs(arguments,"ssl=true")
-	}
-	if config.ReplicaSet != "" {
-		arguments = addArgs(arguments,"replicaSet="+config.ReplicaSet)
-	}
-	var uri = "mongodb://" + config.Host + ":" + config.Port + arguments
-
-	client, err := mongo.NewClient(options.Client().ApplyURI(uri).SetAuth(options.Credential{
-			AuthSource: config.DB, Username: config.Username , Password: config.Password,
-		}).SetTLSConfig(tlsConfig))
+	client, err := mongo.NewClient(config.clientOptions().SetTLSConfig(tlsConfig))
 
 	return client , err
 }
@@ -214,8 +1026,25 @@ func (privilege Privilege) String() string {
 }
 
 type Resource struct {
-	Db         string `json:"db"`
-	Collection string `json:"collection"`
+	Db            string `json:"db"`
+	Collection    string `json:"collection"`
+	Cluster       bool   `json:"cluster"`
+	SystemBuckets string `json:"system_buckets"`
+}
+
+/*
+the cluster resource only holds cluster: true, the buckets of the time
+series collections hold db and system_buckets, the other resources hold db
+and collection even when they are empty
+*/
+func (resource Resource) MarshalBSON() ([]byte, error) {
+	if resource.Cluster {
+		return bson.Marshal(bson.D{{Key: "cluster", Value: true}})
+	}
+	if resource.SystemBuckets != "" {
+		return bson.Marshal(bson.D{{Key: "db", Value: resource.Db}, {Key: "system_buckets", Value: resource.SystemBuckets}})
+	}
+	return bson.Marshal(bson.D{{Key: "db", Value: resource.Db}, {Key: "collection", Value: resource.Collection}})
 }
 
 func (resource Resource) String() string {
@@ -223,15 +1052,121 @@ func (resource Resource) String() string {
 }
 
 
-func createUser(client *mongo.Client, user DbUser, roles []Role, database string) error {
+/*
+the commands are logged at debug level with their secrets redacted
+*/
+var redactedCommandFields = map[string]bool{
+	"pwd":      true,
+	"password": true,
+}
+
+func runCommand(ctx context.Context, db *mongo.Database, command bson.D, opts ...*options.RunCmdOptions) *mongo.SingleResult {
+	tflog.Debug(ctx, "running mongodb command", map[string]interface{}{
+		"command":  command[0].Key,
+		"database": db.Name(),
+		"document": redactedCommand(command),
+	})
+	result := db.RunCommand(ctx, command, opts...)
+	if result.Err() != nil {
+		tflog.Debug(ctx, "mongodb command failed", map[string]interface{}{
+			"command": command[0].Key,
+			"error":   scrubSecrets(result.Err().Error()),
+		})
+	}
+	return result
+}
+
+func redactedCommand(command bson.D) string {
+	redacted := make(bson.D, 0, len(command))
+	for _, element := range command {
+		if redactedCommandFields[element.Key] {
+			element.Value = "***"
+		}
+		redacted = append(redacted, element)
+	}
+	document, err := bson.MarshalExtJSON(redacted, false, false)
+	if err != nil {
+		return err.Error()
+	}
+	return string(document)
+}
+
+/*
+the credentials embedded in connection_uri are not logged
+*/
+func (c *ClientConfig) redactedURI() string {
+	uri, err := url.Parse(c.uri())
+	if err != nil {
+		return "invalid uri"
+	}
+	return uri.Redacted()
+}
+
+/*
+RunCommand ignores the write concern of the client, user and role management
+commands have to carry it in the command document
+*/
+func withWriteConcern(db *mongo.Database, command bson.D) bson.D {
+	writeConcern := db.WriteConcern()
+	if writeConcern == nil {
+		return command
+	}
+	var document bson.D
+	if writeConcern.W != nil {
+		document = append(document, bson.E{Key: "w", Value: writeConcern.W})
+	}
+	if writeConcern.Journal != nil {
+		document = append(document, bson.E{Key: "j", Value: *writeConcern.Journal})
+	}
+	if writeConcern.WTimeout > 0 {
+		document = append(document, bson.E{Key: "wtimeout", Value: writeConcern.WTimeout.Milliseconds()})
+	}
+	if len(document) == 0 {
+		return command
+	}
+	return append(command, bson.E{Key: "writeConcern", Value: document})
+}
+
+func createUser(ctx context.Context, client *mongo.Client, user DbUser, roles []Role, database string) error {
 	var result *mongo.SingleResult
+	var db = client.Database(database)
+	var command = bson.D{{Key: "createUser", Value: user.Name}}
+	/* the users of $external have no password */
+	if user.Password != "" {
+		command = append(command, bson.E{Key: "pwd", Value: user.Password})
+	}
 	if len(roles) != 0  {
-		result = client.Database(database).RunCommand(context.Background(), bson.D{{Key: "createUser", Value: user.Name},
-			{Key: "pwd", Value: user.Password}, {Key: "roles", Value: roles}})
+		documents := make([]Role, 0, len(roles))
+		for _, role := range roles {
+			if role.Db == "" {
+				role.Db = database
+			}
+			documents = append(documents, role)
+		}
+		command = append(command, bson.E{Key: "roles", Value: documents})
 	} else{
-		result = client.Database(database).RunCommand(context.Background(), bson.D{{Key: "createUser", Value: user.Name},
-			{Key: "pwd", Value: user.Password}, {Key: "roles", Value: []bson.M{}}})
+		command = append(command, bson.E{Key: "roles", Value: []bson.M{}})
 	}
+	if len(user.CustomData) != 0 {
+		command = append(command, bson.E{Key: "customData", Value: user.CustomData})
+	}
+	/*
+	a digested password is the hex md5 of user:mongo:password, it can only be
+	used for SCRAM-SHA-1 credentials
+	*/
+	if user.PasswordDigested {
+		command = append(command, bson.E{Key: "digestPassword", Value: false})
+		if len(user.Mechanisms) == 0 {
+			user.Mechanisms = []string{"SCRAM-SHA-1"}
+		}
+	}
+	if len(user.Mechanisms) != 0 {
+		command = append(command, bson.E{Key: "mechanisms", Value: user.Mechanisms})
+	}
+	if len(user.AuthenticationRestrictions) != 0 {
+		command = append(command, bson.E{Key: "authenticationRestrictions", Value: user.AuthenticationRestrictions})
+	}
+	result = runCommand(ctx, db, withWriteConcern(db, command))
 
 	if result.Err() != nil {
 		return result.Err()
@@ -239,13 +1174,253 @@ func createUser(client *mongo.Client, user DbUser, roles []Role, database string
 	return nil
 }
 
-func getUser(client *mongo.Client, username string, database string) (SingleResultGetUser , error) {
+/*
+updateRole replaces the privileges and the inherited roles at once, the
+users of the role keep it during the update
+*/
+func updateRole(ctx context.Context, client *mongo.Client, role string, roles []Role, privilege []PrivilegeDto, database string) error {
+	var db = client.Database(database)
+	privileges := bson.A{}
+	for _, element := range expandPrivileges(privilege) {
+		privileges = append(privileges, element)
+	}
+	inheritedRoles := bson.A{}
+	for _, element := range roles {
+		inheritedRoles = append(inheritedRoles, element)
+	}
+	return runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: "updateRole", Value: role},
+		{Key: "privileges", Value: privileges}, {Key: "roles", Value: inheritedRoles}})).Err()
+}
+
+/*
+grantPrivilegesToRole or revokePrivilegesFromRole, nothing is sent without
+privileges
+*/
+func updateRolePrivileges(ctx context.Context, client *mongo.Client, command string, role string, privilege []PrivilegeDto, database string) error {
+	if len(privilege) == 0 {
+		return nil
+	}
+	var db = client.Database(database)
+	return runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: command, Value: role},
+		{Key: "privileges", Value: expandPrivileges(privilege)}})).Err()
+}
+
+/*
+grantRolesToRole or revokeRolesFromRole, nothing is sent without roles
+*/
+func updateRoleRoles(ctx context.Context, client *mongo.Client, command string, role string, roles []Role, database string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	var db = client.Database(database)
+	return runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: command, Value: role},
+		{Key: "roles", Value: roles}})).Err()
+}
+
+func expandPrivileges(privilege []PrivilegeDto) []Privilege {
+	var privileges []Privilege
+	for _ , element := range privilege {
+		var prv Privilege
+		prv.Resource = Resource{
+			Db:            element.Db,
+			Collection:    element.Collection,
+			Cluster:       element.Cluster,
+			SystemBuckets: element.SystemBuckets,
+		}
+		prv.Actions = element.Actions
+		privileges = append(privileges,prv)
+	}
+	return privileges
+}
+
+/*
+the fields of the update, e.g. pwd or customData, replace the ones of the
+user
+*/
+func updateUser(ctx context.Context, client *mongo.Client, username string, update bson.D, database string) error {
+	if len(update) == 0 {
+		return nil
+	}
+	db := client.Database(database)
+	return runCommand(ctx, db, withWriteConcern(db, append(bson.D{{Key: "updateUser", Value: username}}, update...))).Err()
+}
+
+/*
+the UserNotFound error of the user management commands
+*/
+func isUserNotFound(err error) bool {
+	var commandError mongo.CommandError
+	return errors.As(err, &commandError) && commandError.Code == 11
+}
+
+func isRoleNotFound(err error) bool {
+	var commandError mongo.CommandError
+	return errors.As(err, &commandError) && commandError.Code == 31
+}
+
+/*
+createUser fails with 51003 since mongodb 4.4, with a duplicate key before
+*/
+func isUserAlreadyExists(err error) bool {
+	var commandError mongo.CommandError
+	if errors.As(err, &commandError) && commandError.Code == 51003 {
+		return true
+	}
+	return mongo.IsDuplicateKeyError(err) || strings.Contains(err.Error(), "already exists")
+}
+
+/*
+the roles are granted (or revoked) with grantRolesToUser or
+revokeRolesFromUser, a role without db is one of the database of the user
+*/
+func dropUser(ctx context.Context, client *mongo.Client, username string, database string) error {
+	db := client.Database(database)
+	return runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: "dropUser", Value: username}})).Err()
+}
+
+func updateUserRoles(ctx context.Context, client *mongo.Client, command string, username string, roles []Role, database string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	documents := make([]Role, 0, len(roles))
+	for _, role := range roles {
+		if role.Db == "" {
+			role.Db = database
+		}
+		documents = append(documents, role)
+	}
+	db := client.Database(database)
+	return runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: command, Value: username}, {Key: "roles", Value: documents}})).Err()
+}
+
+/*
+the roles and the privileges a user gets through all its roles
+*/
+type SingleResultGetUserPrivileges struct {
+	Users []struct {
+		User                string                 `json:"user"`
+		Db                  string                 `json:"db"`
+		Roles               []Role                 `json:"roles"`
+		CustomData          map[string]interface{} `json:"customData" bson:"customData"`
+		InheritedRoles      []Role                 `json:"inheritedRoles" bson:"inheritedRoles"`
+		InheritedPrivileges []struct {
+			Resource struct {
+				Db          string `json:"db"`
+				Collection  string `json:"collection"`
+				Cluster     bool   `json:"cluster"`
+				AnyResource bool   `json:"anyResource" bson:"anyResource"`
+			} `json:"resource"`
+			Actions []string `json:"actions"`
+		} `json:"inheritedPrivileges" bson:"inheritedPrivileges"`
+	} `json:"users"`
+}
+
+/*
+the inherited roles and privileges are only returned with showPrivileges
+*/
+func getUserPrivileges(ctx context.Context, client *mongo.Client, username string, database string, showPrivileges bool) (SingleResultGetUserPrivileges, error) {
+	var db = client.Database(database)
+	result := runCommand(ctx, db, bson.D{
+		{Key: "usersInfo", Value: bson.D{{Key: "user", Value: username}, {Key: "db", Value: database}}},
+		{Key: "showPrivileges", Value: showPrivileges},
+	}, options.RunCmd().SetReadPreference(db.ReadPreference()))
+	var decodedResult SingleResultGetUserPrivileges
+	err := result.Decode(&decodedResult)
+	return decodedResult, err
+}
+
+/*
+the users of the database, the filter is applied by the server so the reply
+only holds the matching users, it needs mongodb 4.0 and documentdb and
+cosmos db do not know it, the callers filter the users again.
+the reply of usersInfo is a single document bound by the 16MB limit, the
+users are read with a cursor over admin.system.users instead, by batches.
+without find on admin.system.users, e.g. with userAdmin on the database
+only, or when the server refuses the aggregation, usersInfo is used
+*/
+func listUsers(ctx context.Context, client *mongo.Client, database string, filter bson.D) (SingleResultGetUser, error) {
+	decodedResult, err := listUsersWithCursor(ctx, client, database, filter)
+	var commandError mongo.CommandError
+	if err != nil && errors.As(err, &commandError) {
+		return listUsersInfo(ctx, client, database, filter)
+	}
+	return decodedResult, err
+}
+
+func listUsersWithCursor(ctx context.Context, client *mongo.Client, database string, filter bson.D) (SingleResultGetUser, error) {
+	var decodedResult SingleResultGetUser
+	/* the credentials are never read */
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: append(bson.D{{Key: "db", Value: database}}, filter...)}},
+		{{Key: "$sort", Value: bson.D{{Key: "user", Value: 1}}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "_id", Value: 1},
+			{Key: "user", Value: 1},
+			{Key: "db", Value: 1},
+			{Key: "roles", Value: 1},
+			{Key: "customData", Value: 1},
+		}}},
+	}
+	users := client.Database("admin", options.Database().SetReadPreference(client.Database(database).ReadPreference())).Collection("system.users")
+	cursor, err := users.Aggregate(ctx, pipeline, options.Aggregate().SetBatchSize(usersInfoBatchSize))
+	if err != nil {
+		return decodedResult, err
+	}
+	err = cursor.All(ctx, &decodedResult.Users)
+	return decodedResult, err
+}
+
+func listUsersInfo(ctx context.Context, client *mongo.Client, database string, filter bson.D) (SingleResultGetUser, error) {
+	var db = client.Database(database)
+	command := bson.D{{Key: "usersInfo", Value: 1}}
+	if len(filter) != 0 {
+		command = append(command, bson.E{Key: "filter", Value: filter})
+	}
+	result := runCommand(ctx, db, command, options.RunCmd().SetReadPreference(db.ReadPreference()))
+	var decodedResult SingleResultGetUser
+	err := result.Decode(&decodedResult)
+	return decodedResult, err
+}
+
+/*
+the reply of usersInfo is a single document, the users are read by batches
+so the reply stays under the 16MB limit with tens of thousands of users
+*/
+const usersInfoBatchSize = 500
+
+func getUsers(ctx context.Context, client *mongo.Client, usernames []string, database string) (SingleResultGetUser, error) {
+	var db = client.Database(database)
+	var decodedResult SingleResultGetUser
+	for start := 0; start < len(usernames); start += usersInfoBatchSize {
+		users := bson.A{}
+		for _, username := range usernames[start:min(start+usersInfoBatchSize, len(usernames))] {
+			users = append(users, bson.D{{Key: "user", Value: username}, {Key: "db", Value: database}})
+		}
+		result := runCommand(ctx, db, bson.D{{Key: "usersInfo", Value: users}}, options.RunCmd().SetReadPreference(db.ReadPreference()))
+		var batch SingleResultGetUser
+		if err := result.Decode(&batch); err != nil {
+			return decodedResult, err
+		}
+		decodedResult.Users = append(decodedResult.Users, batch.Users...)
+	}
+	return decodedResult, nil
+}
+
+/*
+documentdb and cosmos db do not know showAuthenticationRestrictions
+*/
+func getUser(ctx context.Context, client *mongo.Client, username string, database string, showAuthenticationRestrictions bool) (SingleResultGetUser , error) {
 	var result *mongo.SingleResult
-	result = client.Database(database).RunCommand(context.Background(), bson.D{{Key: "usersInfo", Value: bson.D{
+	var db = client.Database(database)
+	command := bson.D{{Key: "usersInfo", Value: bson.D{
 		{Key: "user", Value: username},
 		{Key: "db", Value: database},
 	},
-	}})
+	}}
+	if showAuthenticationRestrictions {
+		command = append(command, bson.E{Key: "showAuthenticationRestrictions", Value: true})
+	}
+	result = runCommand(ctx, db, command, options.RunCmd().SetReadPreference(db.ReadPreference()))
 	var decodedResult SingleResultGetUser
 	err := result.Decode(&decodedResult)
 	if err != nil {
@@ -254,15 +1429,16 @@ func getUser(client *mongo.Client, username string, database string) (SingleResu
 	return decodedResult , nil
 }
 
-func getRole(client *mongo.Client, roleName string, database string) (SingleResultGetRole , error)  {
+func getRole(ctx context.Context, client *mongo.Client, roleName string, database string) (SingleResultGetRole , error)  {
 	var result *mongo.SingleResult
-	result = client.Database(database).RunCommand(context.Background(), bson.D{{Key: "rolesInfo", Value: bson.D{
+	var db = client.Database(database)
+	result = runCommand(ctx, db, bson.D{{Key: "rolesInfo", Value: bson.D{
 		{Key: "role", Value: roleName},
 		{Key: "db", Value: database},
 	},
 	},
 	{ Key: "showPrivileges" , Value: true},
-	})
+	}, options.RunCmd().SetReadPreference(db.ReadPreference()))
 	var decodedResult SingleResultGetRole
 	err := result.Decode(&decodedResult)
 	if err != nil {
@@ -271,30 +1447,38 @@ func getRole(client *mongo.Client, roleName string, database string) (SingleResu
 	return decodedResult , nil
 }
 
-func createRole(client *mongo.Client, role string, roles []Role, privilege []PrivilegeDto, database string) error {
-	var privileges []Privilege
+/*
+the roles of the database, the built-in roles are only listed with
+showBuiltinRoles
+*/
+func listRoles(ctx context.Context, client *mongo.Client, database string, showBuiltinRoles bool, showPrivileges bool) (SingleResultGetRole, error) {
+	var db = client.Database(database)
+	result := runCommand(ctx, db, bson.D{
+		{Key: "rolesInfo", Value: 1},
+		{Key: "showBuiltinRoles", Value: showBuiltinRoles},
+		{Key: "showPrivileges", Value: showPrivileges},
+	}, options.RunCmd().SetReadPreference(db.ReadPreference()))
+	var decodedResult SingleResultGetRole
+	err := result.Decode(&decodedResult)
+	return decodedResult, err
+}
+
+func createRole(ctx context.Context, client *mongo.Client, role string, roles []Role, privilege []PrivilegeDto, database string) error {
+	var privileges = expandPrivileges(privilege)
 	var result *mongo.SingleResult
-	for _ , element := range privilege {
-		var prv Privilege
-		prv.Resource = Resource{
-			Db:         element.Db,
-			Collection: element.Collection,
-		}
-		prv.Actions = element.Actions
-		privileges = append(privileges,prv)
-	}
+	var db = client.Database(database)
 	if len(roles) != 0 && len(privileges) != 0 {
-		result = client.Database(database).RunCommand(context.Background(), bson.D{{Key: "createRole", Value: role},
-			{Key: "privileges", Value: privileges}, {Key: "roles", Value: roles}})
+		result = runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: "createRole", Value: role},
+			{Key: "privileges", Value: privileges}, {Key: "roles", Value: roles}}))
 	}else if len(roles) == 0 && len(privileges) != 0 {
-		result = client.Database(database).RunCommand(context.Background(), bson.D{{Key: "createRole", Value: role},
-			{Key: "privileges", Value: privileges}, {Key: "roles", Value: []bson.M{}}})
+		result = runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: "createRole", Value: role},
+			{Key: "privileges", Value: privileges}, {Key: "roles", Value: []bson.M{}}}))
 	}else if len(roles) != 0 && len(privileges) == 0 {
-		result = client.Database(database).RunCommand(context.Background(), bson.D{{Key: "createRole", Value: role},
-			{Key: "privileges", Value: []bson.M{}}, {Key: "roles", Value: roles}})
+		result = runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: "createRole", Value: role},
+			{Key: "privileges", Value: []bson.M{}}, {Key: "roles", Value: roles}}))
 	}else{
-		result = client.Database(database).RunCommand(context.Background(), bson.D{{Key: "createRole", Value: role},
-			{Key: "privileges", Value: []bson.M{}}, {Key: "roles", Value: []bson.M{}}})
+		result = runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: "createRole", Value: role},
+			{Key: "privileges", Value: []bson.M{}}, {Key: "roles", Value: []bson.M{}}}))
 	}
 
 	if result.Err() != nil {
@@ -0,0 +1,108 @@
+package mongodb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"os"
+	"path/filepath"
+)
+
+/*
+the profiles hold provider attributes with the types of the provider
+schema, the blocks like tls are objects
+*/
+type configFile struct {
+	Profiles map[string]map[string]interface{} `json:"profiles"`
+}
+
+func defaultConfigFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".terraform-mongodb", "config.json")
+}
+
+/*
+a missing default file is ignored, a config_file which does not exist is an
+error, as is a profile which is not in the file
+*/
+func loadProfile(path string, profile string) (map[string]interface{}, error) {
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigFile()
+		if path == "" {
+			return nil, nil
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read the config file : %s", err)
+	}
+	var file configFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("could not parse the config file %s : %s", path, err)
+	}
+	values, ok := file.Profiles[profile]
+	if !ok {
+		if !explicit && profile == "default" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("profile %s not found in the config file %s", profile, path)
+	}
+	return values, nil
+}
+
+/*
+the attributes set in the provider block take precedence over the profile,
+which takes precedence over the environment variables and the defaults
+*/
+func applyProfile(d *schema.ResourceData, providerSchema map[string]*schema.Schema, profile map[string]interface{}) error {
+	rawConfig := d.GetRawConfig()
+	for key, value := range profile {
+		attribute, ok := providerSchema[key]
+		if !ok || key == "config_file" || key == "profile" {
+			return fmt.Errorf("%s is not a provider attribute which can be set in the config file", key)
+		}
+		if setInConfig(rawConfig, key) {
+			continue
+		}
+		if nested, isBlock := attribute.Elem.(*schema.Resource); isBlock {
+			if block, isObject := value.(map[string]interface{}); isObject {
+				for name, nestedAttribute := range nested.Schema {
+					if _, set := block[name]; !set && nestedAttribute.Default != nil {
+						block[name] = nestedAttribute.Default
+					}
+				}
+				value = []interface{}{block}
+			}
+		}
+		if err := d.Set(key, value); err != nil {
+			return fmt.Errorf("invalid %s in the config file : %s", key, err)
+		}
+	}
+	return nil
+}
+
+/*
+a block which is not written is an empty list in the raw config
+*/
+func setInConfig(rawConfig cty.Value, key string) bool {
+	if rawConfig.IsNull() || !rawConfig.Type().IsObjectType() || !rawConfig.Type().HasAttribute(key) {
+		return false
+	}
+	value := rawConfig.GetAttr(key)
+	if value.IsNull() {
+		return false
+	}
+	if value.IsKnown() && (value.Type().IsListType() || value.Type().IsSetType()) && value.LengthInt() == 0 {
+		return false
+	}
+	return true
+}
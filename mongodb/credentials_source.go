@@ -0,0 +1,115 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"os"
+)
+
+type CredentialsSource struct {
+	Type              string
+	SecretID          string
+	UsernameParameter string
+	PasswordParameter string
+	Path              string
+	Region            string
+}
+
+/*
+the secrets manager secret and the file hold a json document with username
+and password keys, the format of the rds and documentdb rotation lambdas
+*/
+type storedCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (s *CredentialsSource) credentials(ctx context.Context) (*storedCredentials, error) {
+	switch s.Type {
+	case "aws_secrets_manager":
+		return s.secretsManagerCredentials(ctx)
+	case "aws_ssm":
+		return s.ssmCredentials(ctx)
+	case "file":
+		content, err := os.ReadFile(s.Path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the credentials file : %s", err)
+		}
+		return parseStoredCredentials(content, s.Path)
+	}
+	return nil, fmt.Errorf("unsupported credentials source %s", s.Type)
+}
+
+func (s *CredentialsSource) awsConfig(ctx context.Context) (aws.Config, error) {
+	var loadOptions []func(*config.LoadOptions) error
+	if s.Region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(s.Region))
+	}
+	return config.LoadDefaultConfig(ctx, loadOptions...)
+}
+
+func (s *CredentialsSource) secretsManagerCredentials(ctx context.Context) (*storedCredentials, error) {
+	if s.SecretID == "" {
+		return nil, errors.New("secret_id is required with aws_secrets_manager")
+	}
+	awsConfig, err := s.awsConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	output, err := secretsmanager.NewFromConfig(awsConfig).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.SecretID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read the secret %s : %s", s.SecretID, err)
+	}
+	return parseStoredCredentials([]byte(aws.ToString(output.SecretString)), s.SecretID)
+}
+
+func (s *CredentialsSource) ssmCredentials(ctx context.Context) (*storedCredentials, error) {
+	if s.PasswordParameter == "" {
+		return nil, errors.New("password_parameter is required with aws_ssm")
+	}
+	awsConfig, err := s.awsConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client := ssm.NewFromConfig(awsConfig)
+	credentials := &storedCredentials{}
+	if s.UsernameParameter != "" {
+		if credentials.Username, err = ssmParameter(ctx, client, s.UsernameParameter); err != nil {
+			return nil, err
+		}
+	}
+	if credentials.Password, err = ssmParameter(ctx, client, s.PasswordParameter); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func ssmParameter(ctx context.Context, client *ssm.Client, name string) (string, error) {
+	output, err := client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not read the parameter %s : %s", name, err)
+	}
+	return aws.ToString(output.Parameter.Value), nil
+}
+
+func parseStoredCredentials(content []byte, source string) (*storedCredentials, error) {
+	var credentials storedCredentials
+	if err := json.Unmarshal(content, &credentials); err != nil {
+		return nil, fmt.Errorf("could not parse the credentials of %s : %s", source, err)
+	}
+	if credentials.Password == "" {
+		return nil, fmt.Errorf("the credentials of %s have no password", source)
+	}
+	return &credentials, nil
+}
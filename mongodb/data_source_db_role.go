@@ -0,0 +1,122 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"time"
+)
+
+func dataSourceDatabaseRole() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: tracedOperation("mongodb_db_role.read", dataSourceDatabaseRoleRead),
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(2 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"database": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "admin",
+				Description: "The database of the role",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the role, a custom or a built-in role",
+			},
+			"is_builtin": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"privilege":      dataSourcePrivilegeSchema(),
+			"inherited_role": dataSourceInheritedRoleSchema(),
+		},
+	}
+}
+
+func dataSourcePrivilegeSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Computed: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"db": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"collection": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"cluster": {
+					Type:     schema.TypeBool,
+					Computed: true,
+				},
+				"system_buckets": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"actions": {
+					Type:     schema.TypeList,
+					Computed: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceInheritedRoleSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Computed: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"db": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+				"role": {
+					Type:     schema.TypeString,
+					Computed: true,
+				},
+			},
+		},
+	}
+}
+
+func dataSourceDatabaseRoleRead(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
+	var meta = i.(*ProviderMeta)
+	if diags := cosmosDBUnsupported(meta, "mongodb_db_role"); diags != nil {
+		return diags
+	}
+	var database = data.Get("database").(string)
+	var roleName = data.Get("name").(string)
+
+	var result SingleResultGetRole
+	err := meta.retry(ctx, func() error {
+		var err error
+		result, err = getRole(ctx, meta.Client, roleName, database)
+		return err
+	})
+	if err != nil {
+		return diag.Errorf("Could not read the role %s : %s ", roleName, err)
+	}
+	if len(result.Roles) == 0 {
+		return diag.Errorf("the role %s does not exist in %s", roleName, database)
+	}
+	role := result.Roles[0]
+	inheritedRoles := make([]Role, 0, len(role.InheritedRoles))
+	for _, inherited := range role.InheritedRoles {
+		inheritedRoles = append(inheritedRoles, Role(inherited))
+	}
+	data.Set("is_builtin", role.IsBuiltin)
+	if err := data.Set("privilege", flattenPrivileges(role.Privileges)); err != nil {
+		return diag.Errorf("Error setting the privileges : %s ", err)
+	}
+	data.Set("inherited_role", flattenRoles(inheritedRoles))
+	data.SetId(hex.EncodeToString([]byte(database + "." + roleName)))
+	return nil
+}
@@ -0,0 +1,97 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"time"
+)
+
+func dataSourceDatabaseRoles() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: tracedOperation("mongodb_db_roles.read", dataSourceDatabaseRolesRead),
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(2 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"database": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "admin",
+				Description: "The database the roles are listed from",
+			},
+			"show_builtin_roles": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "List the built-in roles too",
+			},
+			"show_privileges": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Read the privileges of the roles",
+			},
+			"roles": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"database": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"is_builtin": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"privilege":      dataSourcePrivilegeSchema(),
+						"inherited_role": dataSourceInheritedRoleSchema(),
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceDatabaseRolesRead(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
+	var meta = i.(*ProviderMeta)
+	if diags := cosmosDBUnsupported(meta, "mongodb_db_roles"); diags != nil {
+		return diags
+	}
+	var database = data.Get("database").(string)
+
+	var result SingleResultGetRole
+	err := meta.retry(ctx, func() error {
+		var err error
+		result, err = listRoles(ctx, meta.Client, database, data.Get("show_builtin_roles").(bool), data.Get("show_privileges").(bool))
+		return err
+	})
+	if err != nil {
+		return diag.Errorf("Could not list the roles of %s : %s ", database, err)
+	}
+
+	roles := make([]interface{}, 0, len(result.Roles))
+	for _, role := range result.Roles {
+		inheritedRoles := make([]Role, 0, len(role.InheritedRoles))
+		for _, inherited := range role.InheritedRoles {
+			inheritedRoles = append(inheritedRoles, Role(inherited))
+		}
+		roles = append(roles, map[string]interface{}{
+			"name":           role.Role,
+			"database":       role.Db,
+			"is_builtin":     role.IsBuiltin,
+			"privilege":      flattenPrivileges(role.Privileges),
+			"inherited_role": flattenRoles(inheritedRoles),
+		})
+	}
+	if err := data.Set("roles", roles); err != nil {
+		return diag.Errorf("Error setting the roles : %s ", err)
+	}
+	data.SetId(database)
+	return nil
+}
@@ -0,0 +1,151 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"time"
+)
+
+func dataSourceDatabaseUser() *schema.Resource {
+	roleSchema := &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"db": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"role": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+	return &schema.Resource{
+		ReadContext: tracedOperation("mongodb_db_user.read", dataSourceDatabaseUserRead),
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(2 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"auth_database": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The database of the user",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the user",
+			},
+			"show_privileges": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Read the roles and the privileges the user inherits from its roles",
+			},
+			"role": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     roleSchema,
+			},
+			"custom_data": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"inherited_role": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The roles of the user and the roles they inherit, with show_privileges",
+				Elem:        roleSchema,
+			},
+			"privilege": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The privileges the user gets through all its roles, with show_privileges",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"db": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"collection": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"cluster": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"any_resource": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"actions": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceDatabaseUserRead(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
+	var meta = i.(*ProviderMeta)
+	var database = data.Get("auth_database").(string)
+	var userName = data.Get("name").(string)
+	var showPrivileges = data.Get("show_privileges").(bool)
+
+	var result SingleResultGetUserPrivileges
+	err := meta.retry(ctx, func() error {
+		var err error
+		result, err = getUserPrivileges(ctx, meta.Client, userName, database, showPrivileges)
+		return err
+	})
+	if err != nil {
+		return diag.Errorf("Could not read the user %s : %s ", userName, err)
+	}
+	if len(result.Users) == 0 {
+		return diag.Errorf("the user %s does not exist in %s", userName, database)
+	}
+	user := result.Users[0]
+	customData := make(map[string]interface{}, len(user.CustomData))
+	for key, value := range user.CustomData {
+		customData[key] = fmt.Sprint(value)
+	}
+	privileges := make([]interface{}, 0, len(user.InheritedPrivileges))
+	for _, privilege := range user.InheritedPrivileges {
+		privileges = append(privileges, map[string]interface{}{
+			"db":           privilege.Resource.Db,
+			"collection":   privilege.Resource.Collection,
+			"cluster":      privilege.Resource.Cluster,
+			"any_resource": privilege.Resource.AnyResource,
+			"actions":      privilege.Actions,
+		})
+	}
+	if err := data.Set("role", flattenRoles(user.Roles)); err != nil {
+		return diag.Errorf("Error setting the roles : %s ", err)
+	}
+	data.Set("custom_data", customData)
+	data.Set("inherited_role", flattenRoles(user.InheritedRoles))
+	if err := data.Set("privilege", privileges); err != nil {
+		return diag.Errorf("Error setting the privileges : %s ", err)
+	}
+	data.SetId(hex.EncodeToString([]byte(database + "." + userName)))
+	return nil
+}
+
+func flattenRoles(roles []Role) []interface{} {
+	roleList := make([]interface{}, 0, len(roles))
+	for _, role := range roles {
+		roleList = append(roleList, map[string]interface{}{
+			"db":   role.Db,
+			"role": role.Role,
+		})
+	}
+	return roleList
+}
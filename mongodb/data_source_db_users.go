@@ -0,0 +1,163 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+	"time"
+)
+
+func dataSourceDatabaseUsers() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: tracedOperation("mongodb_db_users.read", dataSourceDatabaseUsersRead),
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(2 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"database": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The database the users are listed from",
+			},
+			"name_regex": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsValidRegExp,
+				Description:  "Only list the users whose name matches the regular expression",
+			},
+			"role": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only list the users granted this role",
+			},
+			"role_db": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"role"},
+				Description:  "The database of role, any database when it is not set",
+			},
+			"users": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"auth_database": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"role": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"db": {
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"role": {
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+								},
+							},
+						},
+						"custom_data": {
+							Type:     schema.TypeMap,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceDatabaseUsersRead(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
+	var meta = i.(*ProviderMeta)
+	var database = data.Get("database").(string)
+	var roleName = data.Get("role").(string)
+	var roleDb = data.Get("role_db").(string)
+	var nameRegex *regexp.Regexp
+	if expression := data.Get("name_regex").(string); expression != "" {
+		nameRegex = regexp.MustCompile(expression)
+	}
+
+	var filter bson.D
+	if !meta.Config.DocDBCompatibility && !meta.Config.CosmosDBCompatibility {
+		filter = usersFilter(data.Get("name_regex").(string), roleName, roleDb)
+	}
+
+	var result SingleResultGetUser
+	err := meta.retry(ctx, func() error {
+		var err error
+		result, err = listUsers(ctx, meta.Client, database, filter)
+		return err
+	})
+	if err != nil {
+		return diag.Errorf("Could not list the users of %s : %s ", database, err)
+	}
+
+	users := make([]interface{}, 0, len(result.Users))
+	for _, user := range result.Users {
+		if nameRegex != nil && !nameRegex.MatchString(user.User) {
+			continue
+		}
+		granted := roleName == ""
+		roles := make([]interface{}, 0, len(user.Roles))
+		for _, role := range user.Roles {
+			if role.Role == roleName && (roleDb == "" || role.Db == roleDb) {
+				granted = true
+			}
+			roles = append(roles, map[string]interface{}{
+				"db":   role.Db,
+				"role": role.Role,
+			})
+		}
+		if !granted {
+			continue
+		}
+		customData := make(map[string]interface{}, len(user.CustomData))
+		for key, value := range user.CustomData {
+			customData[key] = fmt.Sprint(value)
+		}
+		users = append(users, map[string]interface{}{
+			"name":          user.User,
+			"auth_database": user.Db,
+			"role":          roles,
+			"custom_data":   customData,
+		})
+	}
+	if err := data.Set("users", users); err != nil {
+		return diag.Errorf("Error setting the users : %s ", err)
+	}
+	data.SetId(database)
+	return nil
+}
+
+/*
+the filter of usersInfo, the regular expressions of go are also valid for
+the server
+*/
+func usersFilter(nameRegex string, roleName string, roleDb string) bson.D {
+	var filter bson.D
+	if nameRegex != "" {
+		filter = append(filter, bson.E{Key: "user", Value: bson.D{{Key: "$regex", Value: nameRegex}}})
+	}
+	if roleName != "" {
+		role := bson.D{{Key: "role", Value: roleName}}
+		if roleDb != "" {
+			role = append(role, bson.E{Key: "db", Value: roleDb})
+		}
+		filter = append(filter, bson.E{Key: "roles", Value: bson.D{{Key: "$elemMatch", Value: role}}})
+	}
+	return filter
+}
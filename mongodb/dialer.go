@@ -0,0 +1,307 @@
+package mongodb
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/net/proxy"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+socks5 is handled by x/net/proxy, http proxies are reached with a CONNECT
+request, the driver wraps the returned connection with tls when ssl is on
+*/
+func proxyDialer(proxyURL string) (options.ContextDialer, error) {
+	parsedURL, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url : %s", err)
+	}
+	switch parsedURL.Scheme {
+	case "socks5", "socks5h":
+		dialer, err := proxy.FromURL(parsedURL, &net.Dialer{})
+		if err != nil {
+			return nil, err
+		}
+		contextDialer, ok := dialer.(proxy.ContextDialer)
+		if !ok {
+			return nil, errors.New("the socks5 dialer does not support contexts")
+		}
+		return contextDialer, nil
+	case "http":
+		return &httpProxyDialer{proxyURL: parsedURL, forward: &net.Dialer{}}, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %s, expected socks5, socks5h or http", parsedURL.Scheme)
+}
+
+type httpProxyDialer struct {
+	proxyURL *url.URL
+	forward  options.ContextDialer
+}
+
+func (d *httpProxyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, network, d.proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if user := d.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response, err := http.ReadResponse(bufio.NewReader(conn), request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused the connection to %s : %s", address, response.Status)
+	}
+	return conn, nil
+}
+
+/*
+the resolver is only used to dial the mongodb hosts directly, the proxy or
+the ssh host resolves them otherwise
+*/
+func resolverDialer(resolverAddress string) options.ContextDialer {
+	return &net.Dialer{
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, resolverAddress)
+			},
+		},
+	}
+}
+
+/*
+the overrides replace the host of the address, its port is kept, tls still
+verifies the certificate against the original host name
+*/
+type hostOverridesDialer struct {
+	overrides map[string]string
+	forward   options.ContextDialer
+}
+
+func (d *hostOverridesDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err == nil {
+		if override, ok := d.overrides[host]; ok {
+			address = net.JoinHostPort(override, port)
+		}
+	}
+	return d.forward.DialContext(ctx, network, address)
+}
+
+type SSHConfig struct {
+	Host               string
+	Port               int
+	User               string
+	Password           string
+	PrivateKey         string
+	PrivateKeyPassword string
+	HostKey            string
+	Bastion            *SSHConfig
+}
+
+func (s *SSHConfig) address() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+/*
+the host key is either pinned with host_key or looked up in ~/.ssh/known_hosts
+*/
+func (s *SSHConfig) clientConfig() (*ssh.ClientConfig, error) {
+	var authMethods []ssh.AuthMethod
+	if s.PrivateKey != "" {
+		var signer ssh.Signer
+		var err error
+		if s.PrivateKeyPassword != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(s.PrivateKey), []byte(s.PrivateKeyPassword))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(s.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the ssh private key of %s : %s", s.Host, err)
+		}
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+	if s.Password != "" {
+		authMethods = append(authMethods, ssh.Password(s.Password))
+	}
+	var hostKeyCallback ssh.HostKeyCallback
+	if s.HostKey != "" {
+		hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(s.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the ssh host key of %s : %s", s.Host, err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(hostKey)
+	} else {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		hostKeyCallback, err = knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+		if err != nil {
+			return nil, fmt.Errorf("could not load the ssh known hosts, set host_key for %s : %s", s.Host, err)
+		}
+	}
+	return &ssh.ClientConfig{
+		User:            s.User,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
+	}, nil
+}
+
+/*
+the tunnel is opened on the first dial and shared by the connections of the
+driver, it is only opened again when the ssh transport is dead, a host which
+can not be reached through it does not close the other connections
+*/
+type sshDialer struct {
+	config  *SSHConfig
+	mutex   sync.Mutex
+	client  *ssh.Client
+	bastion *ssh.Client
+}
+
+/*
+the tunnels are closed with the clients when terraform stops the provider
+*/
+var sshTunnels struct {
+	sync.Mutex
+	open map[*sshDialer]bool
+}
+
+func (d *sshDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	client, err := d.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, address)
+	if err != nil {
+		if _, _, keepaliveErr := client.SendRequest("keepalive@openssh.com", true, nil); keepaliveErr != nil {
+			d.reset(client)
+		}
+		return nil, fmt.Errorf("could not reach %s through the ssh tunnel : %s", address, err)
+	}
+	return conn, nil
+}
+
+func (d *sshDialer) sshClient(ctx context.Context) (*ssh.Client, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.client != nil {
+		return d.client, nil
+	}
+	var forward options.ContextDialer = &net.Dialer{}
+	var bastion *ssh.Client
+	if d.config.Bastion != nil {
+		var err error
+		if bastion, err = dialSSH(ctx, forward, d.config.Bastion); err != nil {
+			return nil, err
+		}
+		forward = bastion
+	}
+	client, err := dialSSH(ctx, forward, d.config)
+	if err != nil {
+		if bastion != nil {
+			bastion.Close()
+		}
+		return nil, err
+	}
+	d.client = client
+	d.bastion = bastion
+	sshTunnels.Lock()
+	if sshTunnels.open == nil {
+		sshTunnels.open = map[*sshDialer]bool{}
+	}
+	sshTunnels.open[d] = true
+	sshTunnels.Unlock()
+	return client, nil
+}
+
+/*
+only the client which failed is closed, another dial may have opened the
+tunnel again meanwhile
+*/
+func (d *sshDialer) reset(client *ssh.Client) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.client == client {
+		d.close()
+	}
+}
+
+func (d *sshDialer) close() {
+	if d.client != nil {
+		d.client.Close()
+		d.client = nil
+	}
+	if d.bastion != nil {
+		d.bastion.Close()
+		d.bastion = nil
+	}
+}
+
+/*
+the dialers lock their mutex before the tunnels, they are closed after the
+tunnels are released
+*/
+func closeSSHTunnels() {
+	sshTunnels.Lock()
+	open := sshTunnels.open
+	sshTunnels.open = nil
+	sshTunnels.Unlock()
+	for dialer := range open {
+		dialer.mutex.Lock()
+		dialer.close()
+		dialer.mutex.Unlock()
+	}
+}
+
+func dialSSH(ctx context.Context, forward options.ContextDialer, config *SSHConfig) (*ssh.Client, error) {
+	clientConfig, err := config.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := forward.DialContext(ctx, "tcp", config.address())
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to the ssh host %s : %s", config.Host, err)
+	}
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, config.address(), clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not open the ssh session with %s : %s", config.Host, err)
+	}
+	return ssh.NewClient(sshConn, channels, requests), nil
+}
@@ -0,0 +1,204 @@
+package mongodb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+/*
+the user is created when the ephemeral resource is opened and dropped when
+it is closed, the password only lives in memory for the run
+*/
+type temporaryUserEphemeralResource struct {
+	meta *ProviderMeta
+}
+
+type temporaryUserModel struct {
+	AuthDatabase types.String        `tfsdk:"auth_database"`
+	NamePrefix   types.String        `tfsdk:"name_prefix"`
+	Role         []temporaryUserRole `tfsdk:"role"`
+	Name         types.String        `tfsdk:"name"`
+	Password     types.String        `tfsdk:"password"`
+}
+
+type temporaryUserRole struct {
+	Db   types.String `tfsdk:"db"`
+	Role types.String `tfsdk:"role"`
+}
+
+/*
+kept in the private data between open and close
+*/
+type temporaryUserPrivate struct {
+	AuthDatabase string `json:"auth_database"`
+	Name         string `json:"name"`
+}
+
+func NewTemporaryUserEphemeralResource() ephemeral.EphemeralResource {
+	return &temporaryUserEphemeralResource{}
+}
+
+func (r *temporaryUserEphemeralResource) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_temporary_user"
+}
+
+func (r *temporaryUserEphemeralResource) Schema(_ context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "A database user which only exists for the duration of the run",
+		Attributes: map[string]schema.Attribute{
+			"auth_database": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The database the user is created in, defaults to admin",
+			},
+			"name_prefix": schema.StringAttribute{
+				Optional:    true,
+				Computed:    true,
+				Description: "The prefix of the generated user name, defaults to terraform-",
+			},
+			"name": schema.StringAttribute{
+				Computed:    true,
+				Description: "The generated user name",
+			},
+			"password": schema.StringAttribute{
+				Computed:    true,
+				Sensitive:   true,
+				Description: "The generated password",
+			},
+		},
+		Blocks: map[string]schema.Block{
+			"role": schema.ListNestedBlock{
+				Description: "The roles granted to the user",
+				NestedObject: schema.NestedBlockObject{
+					Attributes: map[string]schema.Attribute{
+						"db": schema.StringAttribute{
+							Optional:    true,
+							Description: "The database of the role",
+						},
+						"role": schema.StringAttribute{
+							Required:    true,
+							Description: "The role name",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *temporaryUserEphemeralResource) Configure(_ context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	meta, ok := req.ProviderData.(*ProviderMeta)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected provider data", fmt.Sprintf("expected *ProviderMeta, got %T", req.ProviderData))
+		return
+	}
+	r.meta = meta
+}
+
+func (r *temporaryUserEphemeralResource) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	ctx, span := tracer.Start(ctx, "mongodb_temporary_user.open")
+	defer span.End()
+	if r.meta == nil || r.meta.Client == nil {
+		if req.ClientCapabilities.DeferralAllowed {
+			resp.Deferred = &ephemeral.Deferred{Reason: ephemeral.DeferredReasonProviderConfigUnknown}
+			return
+		}
+		resp.Diagnostics.AddError("Provider not configured", "the mongodb provider must be configured to create a temporary user")
+		return
+	}
+	var data temporaryUserModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	database := "admin"
+	if data.AuthDatabase.ValueString() != "" {
+		database = data.AuthDatabase.ValueString()
+	}
+	prefix := "terraform-"
+	if !data.NamePrefix.IsNull() {
+		prefix = data.NamePrefix.ValueString()
+	}
+	suffix, err := randomBytes(8)
+	if err != nil {
+		resp.Diagnostics.AddError("Could not generate the user name", err.Error())
+		return
+	}
+	password, err := randomBytes(24)
+	if err != nil {
+		resp.Diagnostics.AddError("Could not generate the password", err.Error())
+		return
+	}
+	user := DbUser{
+		Name:     prefix + hex.EncodeToString(suffix),
+		Password: base64.RawURLEncoding.EncodeToString(password),
+	}
+	var roleList []Role
+	for _, role := range data.Role {
+		roleList = append(roleList, Role{Role: role.Role.ValueString(), Db: role.Db.ValueString()})
+	}
+	err = r.meta.retry(ctx, func() error {
+		return createUser(ctx, r.meta.Client, user, roleList, database)
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Could not create the temporary user", scrubSecrets(err.Error(), user.Password))
+		return
+	}
+	private, err := json.Marshal(temporaryUserPrivate{AuthDatabase: database, Name: user.Name})
+	if err != nil {
+		resp.Diagnostics.AddError("Could not store the temporary user", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, "user", private)...)
+
+	data.AuthDatabase = types.StringValue(database)
+	data.NamePrefix = types.StringValue(prefix)
+	data.Name = types.StringValue(user.Name)
+	data.Password = types.StringValue(user.Password)
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &data)...)
+}
+
+func (r *temporaryUserEphemeralResource) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	ctx, span := tracer.Start(ctx, "mongodb_temporary_user.close")
+	defer span.End()
+	if r.meta == nil {
+		resp.Diagnostics.AddError("Provider not configured", "the mongodb provider must be configured to drop a temporary user")
+		return
+	}
+	private, diags := req.Private.GetKey(ctx, "user")
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() || private == nil {
+		return
+	}
+	var user temporaryUserPrivate
+	if err := json.Unmarshal(private, &user); err != nil {
+		resp.Diagnostics.AddError("Could not read the temporary user", err.Error())
+		return
+	}
+	db := r.meta.Client.Database(user.AuthDatabase)
+	err := r.meta.retry(ctx, func() error {
+		return runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: "dropUser", Value: user.Name}})).Err()
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Could not drop the temporary user", fmt.Sprintf("%s : %s", user.Name, scrubSecrets(err.Error())))
+	}
+}
+
+func randomBytes(length int) ([]byte, error) {
+	buffer := make([]byte, length)
+	if _, err := rand.Read(buffer); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
@@ -0,0 +1,199 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	sdkschema "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+/*
+the framework provider is muxed with the sdk provider, it serves the
+provider functions, the ephemeral resources and the resources and data
+sources which need the framework, the existing sdk resources are moved one
+at a time, both must declare the same provider schema so it is converted
+from the sdk one
+*/
+type frameworkProvider struct {
+	version     string
+	sdkProvider *sdkschema.Provider
+}
+
+func NewFrameworkProvider(version string, sdkProvider *sdkschema.Provider) provider.Provider {
+	return &frameworkProvider{
+		version:     version,
+		sdkProvider: sdkProvider,
+	}
+}
+
+func (p *frameworkProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "mongodb"
+	resp.Version = p.version
+}
+
+func (p *frameworkProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
+	attributes, blocks, err := frameworkSchema(p.sdkProvider.Schema)
+	if err != nil {
+		resp.Diagnostics.AddError("Invalid provider schema", err.Error())
+		return
+	}
+	resp.Schema = schema.Schema{
+		Attributes: attributes,
+		Blocks:     blocks,
+	}
+}
+
+/*
+the mux configures the sdk provider first, its meta is shared
+*/
+func (p *frameworkProvider) Configure(_ context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	if !req.Config.Raw.IsFullyKnown() && req.ClientCapabilities.DeferralAllowed {
+		resp.Deferred = &provider.Deferred{Reason: provider.DeferredReasonProviderConfigUnknown}
+		return
+	}
+	if meta, ok := p.sdkProvider.Meta().(*ProviderMeta); ok {
+		resp.ResourceData = meta
+		resp.DataSourceData = meta
+		resp.EphemeralResourceData = meta
+	}
+}
+
+func (p *frameworkProvider) Resources(_ context.Context) []func() resource.Resource {
+	return []func() resource.Resource{
+		NewDatabaseUsersResource,
+		NewDatabaseRolesResource,
+	}
+}
+
+func (p *frameworkProvider) DataSources(_ context.Context) []func() datasource.DataSource {
+	return nil
+}
+
+func (p *frameworkProvider) EphemeralResources(_ context.Context) []func() ephemeral.EphemeralResource {
+	return []func() ephemeral.EphemeralResource{
+		NewTemporaryUserEphemeralResource,
+	}
+}
+
+func (p *frameworkProvider) Functions(_ context.Context) []func() function.Function {
+	return []func() function.Function{
+		NewConnectionStringFunction,
+	}
+}
+
+func frameworkSchema(sdkSchema map[string]*sdkschema.Schema) (map[string]schema.Attribute, map[string]schema.Block, error) {
+	attributes := map[string]schema.Attribute{}
+	blocks := map[string]schema.Block{}
+	for name, s := range sdkSchema {
+		if nested, ok := s.Elem.(*sdkschema.Resource); ok {
+			nestedAttributes, nestedBlocks, err := frameworkSchema(nested.Schema)
+			if err != nil {
+				return nil, nil, err
+			}
+			object := schema.NestedBlockObject{
+				Attributes: nestedAttributes,
+				Blocks:     nestedBlocks,
+			}
+			switch s.Type {
+			case sdkschema.TypeList:
+				blocks[name] = schema.ListNestedBlock{
+					NestedObject:       object,
+					Description:        s.Description,
+					DeprecationMessage: s.Deprecated,
+				}
+			case sdkschema.TypeSet:
+				blocks[name] = schema.SetNestedBlock{
+					NestedObject:       object,
+					Description:        s.Description,
+					DeprecationMessage: s.Deprecated,
+				}
+			default:
+				return nil, nil, fmt.Errorf("%s : unsupported block type %s", name, s.Type)
+			}
+			continue
+		}
+		attribute, err := frameworkAttribute(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s : %s", name, err)
+		}
+		attributes[name] = attribute
+	}
+	return attributes, blocks, nil
+}
+
+/*
+like the sdk, a required attribute with a default value is optional
+*/
+func frameworkAttribute(s *sdkschema.Schema) (schema.Attribute, error) {
+	required := s.Required
+	optional := s.Optional
+	if required && s.DefaultFunc != nil {
+		if value, err := s.DefaultFunc(); err != nil || value != nil {
+			required = false
+			optional = true
+		}
+	}
+	switch s.Type {
+	case sdkschema.TypeString:
+		return schema.StringAttribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}, nil
+	case sdkschema.TypeBool:
+		return schema.BoolAttribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}, nil
+	case sdkschema.TypeInt:
+		return schema.Int64Attribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}, nil
+	case sdkschema.TypeFloat:
+		return schema.Float64Attribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}, nil
+	}
+	elementType, err := frameworkElementType(s.Elem)
+	if err != nil {
+		return nil, err
+	}
+	switch s.Type {
+	case sdkschema.TypeList:
+		return schema.ListAttribute{ElementType: elementType, Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}, nil
+	case sdkschema.TypeSet:
+		return schema.SetAttribute{ElementType: elementType, Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}, nil
+	case sdkschema.TypeMap:
+		return schema.MapAttribute{ElementType: elementType, Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}, nil
+	}
+	return nil, fmt.Errorf("unsupported attribute type %s", s.Type)
+}
+
+/*
+the sdk defaults the elements of a map without elem to strings
+*/
+func frameworkElementType(elem interface{}) (attr.Type, error) {
+	s, ok := elem.(*sdkschema.Schema)
+	if !ok {
+		return types.StringType, nil
+	}
+	switch s.Type {
+	case sdkschema.TypeString:
+		return types.StringType, nil
+	case sdkschema.TypeBool:
+		return types.BoolType, nil
+	case sdkschema.TypeInt:
+		return types.Int64Type, nil
+	case sdkschema.TypeFloat:
+		return types.Float64Type, nil
+	}
+	elementType, err := frameworkElementType(s.Elem)
+	if err != nil {
+		return nil, err
+	}
+	switch s.Type {
+	case sdkschema.TypeList:
+		return types.ListType{ElemType: elementType}, nil
+	case sdkschema.TypeSet:
+		return types.SetType{ElemType: elementType}, nil
+	case sdkschema.TypeMap:
+		return types.MapType{ElemType: elementType}, nil
+	}
+	return nil, fmt.Errorf("unsupported element type %s", s.Type)
+}
@@ -0,0 +1,88 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"net/url"
+	"strings"
+)
+
+type connectionStringFunction struct{}
+
+func NewConnectionStringFunction() function.Function {
+	return &connectionStringFunction{}
+}
+
+func (f *connectionStringFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "connection_string"
+}
+
+func (f *connectionStringFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Build a mongodb connection string",
+		Description: "Builds a mongodb connection string from a seed list, credentials and options, the credentials and the options are escaped",
+		Parameters: []function.Parameter{
+			function.ListParameter{
+				Name:        "hosts",
+				ElementType: types.StringType,
+				Description: "The mongodb seed list, entries without a port use port 27017",
+			},
+			function.StringParameter{
+				Name:           "username",
+				AllowNullValue: true,
+				Description:    "The username, null leaves the credentials out",
+			},
+			function.StringParameter{
+				Name:           "password",
+				AllowNullValue: true,
+				Description:    "The password, null leaves the password out",
+			},
+			function.MapParameter{
+				Name:           "options",
+				ElementType:    types.StringType,
+				AllowNullValue: true,
+				Description:    "The connection string options, e.g. replicaSet or authSource",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *connectionStringFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var hosts []string
+	var username, password types.String
+	var options map[string]string
+	resp.Error = req.Arguments.Get(ctx, &hosts, &username, &password, &options)
+	if resp.Error != nil {
+		return
+	}
+	if len(hosts) == 0 {
+		resp.Error = function.NewArgumentFuncError(0, "hosts must contain at least one host")
+		return
+	}
+	config := &ClientConfig{Port: "27017"}
+	for _, host := range hosts {
+		if strings.TrimSpace(host) == "" {
+			resp.Error = function.NewArgumentFuncError(0, "hosts must not contain empty hosts")
+			return
+		}
+		config.Hosts = append(config.Hosts, host)
+	}
+	var user *url.Userinfo
+	if !username.IsNull() {
+		if password.IsNull() {
+			user = url.User(username.ValueString())
+		} else {
+			user = url.UserPassword(username.ValueString(), password.ValueString())
+		}
+	} else if !password.IsNull() {
+		resp.Error = function.NewArgumentFuncError(2, "password requires a username")
+		return
+	}
+	arguments := url.Values{}
+	for key, value := range options {
+		arguments.Set(key, value)
+	}
+	resp.Error = resp.Result.Set(ctx, connectionString("mongodb", config.seedList(), user, arguments))
+}
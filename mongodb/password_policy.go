@@ -0,0 +1,148 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"strings"
+	"unicode"
+)
+
+/*
+the password policy of the provider block, the passwords of the users are
+checked against it during the plan
+*/
+type PasswordPolicy struct {
+	MinLength        int
+	RequireLowercase bool
+	RequireUppercase bool
+	RequireDigit     bool
+	RequireSpecial   bool
+	DenyList         []string
+}
+
+func passwordPolicySchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"min_length": {
+			Type:        schema.TypeInt,
+			Optional:    true,
+			Default:     0,
+			Description: "The minimum length of the passwords",
+		},
+		"require_lowercase": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "The passwords must hold a lowercase letter",
+		},
+		"require_uppercase": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "The passwords must hold an uppercase letter",
+		},
+		"require_digit": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "The passwords must hold a digit",
+		},
+		"require_special": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "The passwords must hold a character which is not a letter nor a digit",
+		},
+		"deny_list": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+			Description: "The passwords which are refused, compared without case",
+		},
+	}
+}
+
+func expandPasswordPolicy(policyList []interface{}) *PasswordPolicy {
+	if len(policyList) == 0 || policyList[0] == nil {
+		return nil
+	}
+	policy := policyList[0].(map[string]interface{})
+	passwordPolicy := &PasswordPolicy{
+		MinLength:        policy["min_length"].(int),
+		RequireLowercase: policy["require_lowercase"].(bool),
+		RequireUppercase: policy["require_uppercase"].(bool),
+		RequireDigit:     policy["require_digit"].(bool),
+		RequireSpecial:   policy["require_special"].(bool),
+	}
+	for _, denied := range policy["deny_list"].([]interface{}) {
+		passwordPolicy.DenyList = append(passwordPolicy.DenyList, denied.(string))
+	}
+	return passwordPolicy
+}
+
+/*
+the error does not hold the password
+*/
+func (p *PasswordPolicy) validate(password string) error {
+	var violations []string
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("be at least %d characters long", p.MinLength))
+	}
+	checks := []struct {
+		required bool
+		match    func(rune) bool
+		message  string
+	}{
+		{p.RequireLowercase, unicode.IsLower, "hold a lowercase letter"},
+		{p.RequireUppercase, unicode.IsUpper, "hold an uppercase letter"},
+		{p.RequireDigit, unicode.IsDigit, "hold a digit"},
+		{p.RequireSpecial, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }, "hold a special character"},
+	}
+	for _, check := range checks {
+		if check.required && strings.IndexFunc(password, check.match) < 0 {
+			violations = append(violations, check.message)
+		}
+	}
+	for _, denied := range p.DenyList {
+		if strings.EqualFold(password, denied) {
+			violations = append(violations, "not be in the deny list")
+			break
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("the password does not follow the password_policy of the provider, it must %s", strings.Join(violations, ", "))
+}
+
+/*
+the unknown passwords, e.g. generated during the apply, and the digested
+passwords can not be checked
+*/
+func validateUserPasswordPolicy(_ context.Context, d *schema.ResourceDiff, i interface{}) error {
+	meta, ok := i.(*ProviderMeta)
+	if !ok || meta.Config == nil || meta.Config.PasswordPolicy == nil || !d.Get("digest_password").(bool) {
+		return nil
+	}
+	if d.Get("auth_database").(string) == "$external" {
+		return nil
+	}
+	var password string
+	rawConfig := d.GetRawConfig()
+	if setInConfig(rawConfig, "password_wo") {
+		value := rawConfig.GetAttr("password_wo")
+		if !value.IsKnown() {
+			return nil
+		}
+		password = value.AsString()
+	} else {
+		if !d.NewValueKnown("password") || !d.HasChange("password") {
+			return nil
+		}
+		password = d.Get("password").(string)
+	}
+	if password == "" {
+		return nil
+	}
+	return meta.Config.PasswordPolicy.validate(password)
+}
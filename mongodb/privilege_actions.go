@@ -0,0 +1,105 @@
+package mongodb
+
+import (
+	"fmt"
+	"slices"
+)
+
+/*
+the privilege actions of mongodb 8.0, see
+https://www.mongodb.com/docs/manual/reference/privilege-actions/
+the typos fail during the plan instead of the apply, an action which is not
+close to a known one is only a warning as it may be newer than this list
+*/
+var privilegeActions = []string{
+	/* query and write */
+	"find", "insert", "remove", "update", "bypassDocumentValidation", "useUUID",
+	/* database management */
+	"changeCustomData", "changeOwnCustomData", "changeOwnPassword", "changePassword",
+	"createCollection", "createIndex", "createRole", "createUser", "dropCollection",
+	"dropRole", "dropUser", "enableProfiler", "grantRole", "killCursors", "killAnyCursor",
+	"planCacheIndexFilter", "revokeRole", "setAuthenticationRestriction",
+	"setFeatureCompatibilityVersion", "unlock", "viewRole", "viewUser",
+	/* deployment management */
+	"authSchemaUpgrade", "cleanupOrphaned", "cpuProfiler", "inprog", "invalidateUserCache",
+	"killop", "planCacheRead", "planCacheWrite", "storageDetails", "setUserWriteBlockMode",
+	"bypassWriteBlockingMode", "listCachedAndActiveUsers", "querySettings",
+	/* change streams */
+	"changeStream",
+	/* replication */
+	"appendOplogNote", "replSetConfigure", "replSetGetConfig", "replSetGetStatus",
+	"replSetHeartbeat", "replSetResizeOplog", "replSetStateChange", "resync",
+	/* sharding */
+	"addShard", "analyzeShardKey", "checkMetadataConsistency", "clearJumboFlag",
+	"configureQueryAnalyzer", "enableSharding", "refineCollectionShardKey",
+	"reshardCollection", "flushRouterConfig", "getClusterParameter", "setClusterParameter",
+	"getShardVersion", "issueDirectShardOperations", "listShards", "moveChunk", "moveCollection",
+	"removeShard", "shardingState", "splitChunk", "splitVector", "getShardMap", "shardCollection",
+	"shardedDataDistribution", "unshardCollection", "transitionFromDedicatedConfigServer",
+	"transitionToDedicatedConfigServer",
+	/* server administration */
+	"applicationMessage", "bypassDefaultMaxTimeMS", "closeAllDatabases", "collMod", "compact",
+	"compactStructuredEncryptionData", "cleanupStructuredEncryptionData", "connPoolSync",
+	"convertToCapped", "dropConnections", "dropDatabase", "dropIndex", "forceUUID", "fsync",
+	"getDefaultRWConcern", "getParameter", "hostInfo", "logRotate", "reIndex",
+	"renameCollectionSameDB", "rotateCertificates", "setDefaultRWConcern", "setParameter",
+	"shutdown", "touch", "oidReset", "queryStatsRead", "queryStatsReadTransformed",
+	/* sessions */
+	"impersonate", "listSessions", "killAnySession",
+	/* free monitoring */
+	"checkFreeMonitoringStatus", "setFreeMonitoring",
+	/* diagnostics */
+	"collStats", "connPoolStats", "dbHash", "dbStats", "getCmdLineOpts", "getLog",
+	"indexStats", "listDatabases", "listCollections", "listIndexes", "netstat",
+	"operationMetrics", "serverStatus", "top", "validate",
+	/* search indexes */
+	"createSearchIndexes", "dropSearchIndex", "listSearchIndexes", "updateSearchIndex",
+	/* internal */
+	"anyAction", "internal", "applyOps",
+}
+
+func validatePrivilegeAction(v interface{}, k string) (warnings []string, errors []error) {
+	action := v.(string)
+	if slices.Contains(privilegeActions, action) {
+		return nil, nil
+	}
+	if suggestion := closestPrivilegeAction(action); suggestion != "" {
+		return nil, []error{fmt.Errorf("expected %s to be a privilege action, got %q, did you mean %q", k, action, suggestion)}
+	}
+	return []string{fmt.Sprintf("%s : %q is not a known privilege action, it is sent as is, see https://www.mongodb.com/docs/manual/reference/privilege-actions/", k, action)}, nil
+}
+
+/*
+the known action at the smallest edit distance, when it is close enough to
+be a typo
+*/
+func closestPrivilegeAction(action string) string {
+	var closest string
+	best := len(action)/3 + 1
+	for _, candidate := range privilegeActions {
+		if distance := editDistance(action, candidate); distance < best || (distance == best && closest != "" && candidate < closest) {
+			closest, best = candidate, distance
+		}
+	}
+	return closest
+}
+
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
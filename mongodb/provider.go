@@ -2,24 +2,44 @@ package mongodb
 
 import (
 	"context"
+	"crypto/fips140"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func Provider() *schema.Provider {
+func Provider(version string) *schema.Provider {
 	return &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"host": {
 				Type:        schema.TypeString,
 				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("MONGO_HOST", "127.0.0.1"),
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"MONGODB_HOST", "MONGO_HOST"}, "127.0.0.1"),
 				Description: "The mongodb server address",
 			},
-			"port": {
+			"hosts": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "The mongodb seed list, entries without a port use port, host is ignored when set",
+			},
+			"socket_path": {
 				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("MONGO_PORT", "27017"),
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_SOCKET_PATH", ""),
+				Description: "Path to the unix domain socket of the mongodb server, host, hosts and port are ignored when set",
+			},
+			"port": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.MultiEnvDefaultFunc([]string{"MONGODB_PORT", "MONGO_PORT"}, "27017"),
+				ValidateFunc: validatePort,
 				Description: "The mongodb server port",
 			},
 			"certificate": {
@@ -28,58 +48,661 @@ func Provider() *schema.Provider {
 				DefaultFunc: schema.EnvDefaultFunc("MONGODB_CERT", ""),
 				Description: "PEM-encoded content of Mongodb host CA certificate",
 			},
-
+			"client_certificate": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_CLIENT_CERT", ""),
+				Description: "PEM-encoded content of the client certificate",
+			},
+			"client_key": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_CLIENT_KEY", ""),
+				Description: "PEM-encoded content of the client certificate private key",
+			},
+			"ca_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("MONGODB_CA_FILE", ""),
+				ConflictsWith: []string{"certificate"},
+				Description:   "Path to the PEM-encoded Mongodb host CA certificate",
+			},
+			"cert_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("MONGODB_CERT_FILE", ""),
+				ConflictsWith: []string{"client_certificate"},
+				Description:   "Path to the PEM-encoded client certificate",
+			},
+			"key_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("MONGODB_KEY_FILE", ""),
+				ConflictsWith: []string{"client_key"},
+				Description:   "Path to the PEM-encoded client certificate private key",
+			},
+			"key_password": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_KEY_PASSWORD", ""),
+				Description: "The passphrase of the encrypted client certificate private key",
+			},
+			"pkcs12_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				DefaultFunc:   schema.EnvDefaultFunc("MONGODB_PKCS12_FILE", ""),
+				ConflictsWith: []string{"client_certificate", "cert_file"},
+				Description:   "Path to a PKCS#12 bundle with the client certificate, its private key and the CA chain",
+			},
+			"pkcs12_password": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_PKCS12_PASSWORD", ""),
+				Description: "The password of the PKCS#12 bundle",
+			},
 			"username": {
 				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("MONGO_USR", nil),
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"MONGODB_USERNAME", "MONGO_USR"}, nil),
 				Description: "The mongodb user",
 			},
 			"password": {
 				Type:        schema.TypeString,
-				Required:    true,
-				DefaultFunc: schema.EnvDefaultFunc("MONGO_PWD", nil),
+				Optional:    true,
+				DefaultFunc: schema.MultiEnvDefaultFunc([]string{"MONGODB_PASSWORD", "MONGO_PWD"}, nil),
 				Description: "The mongodb password",
 			},
+			"aws_session_token": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Default:     "",
+				Description: "The AWS session token used with the MONGODB-AWS auth mechanism",
+			},
+			"gssapi_service_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "The kerberos service name used with the GSSAPI auth mechanism, mongodb when empty",
+			},
+			"gssapi_service_realm": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "The kerberos realm of the service used with the GSSAPI auth mechanism",
+			},
+			"gssapi_canonicalize_host_name": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "canonicalize the host name with a reverse DNS lookup for the GSSAPI auth mechanism",
+			},
+			"oidc_token_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "Path to a file containing the OIDC access token used with the MONGODB-OIDC auth mechanism",
+			},
+			"oidc_environment": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "",
+				ValidateFunc: validation.StringInSlice([]string{"", "azure", "gcp"}, false),
+				ConflictsWith: []string{"oidc_token_file"},
+				Description:  "The cloud environment the OIDC access token is fetched from",
+			},
+			"oidc_token_resource": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "The audience of the OIDC access token fetched from oidc_environment",
+			},
 			"auth_database": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Default:     "admin",
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_AUTH_DATABASE", "admin"),
 				Description: "The mongodb auth database",
 			},
+			"auth_source": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_AUTH_SOURCE", ""),
+				Description: "The database the provider user is authenticated against, auth_database when empty",
+			},
+			"auth_mechanism": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("MONGODB_AUTH_MECHANISM", ""),
+				ValidateFunc: validation.StringInSlice([]string{"", "SCRAM-SHA-1", "SCRAM-SHA-256", "MONGODB-X509", "MONGODB-AWS", "GSSAPI", "MONGODB-OIDC"}, false),
+				Description:  "The mongodb authentication mechanism, negotiated with the server when empty",
+			},
 			"replica_set": {
 				Type:        schema.TypeString,
 				Optional:    true,
-				Default:     "",
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_REPLICA_SET", ""),
 				Description: "The mongodb replica set",
 			},
+			"proxy_url": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				Default:     "",
+				Description: "The socks5 or http proxy used to reach the mongodb server",
+			},
+			"password_policy": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "The policy the passwords of mongodb_db_user are checked against during the plan",
+				Elem: &schema.Resource{
+					Schema: passwordPolicySchema(),
+				},
+			},
+			"credentials_source": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Where the username and password are fetched from when the provider is configured",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"type": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice([]string{"aws_secrets_manager", "aws_ssm", "file"}, false),
+							Description:  "aws_secrets_manager, aws_ssm or file",
+						},
+						"secret_id": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The name or ARN of the secret, a json document with username and password keys",
+						},
+						"username_parameter": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The SSM parameter holding the username",
+						},
+						"password_parameter": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The SSM parameter holding the password, SecureString parameters are decrypted",
+						},
+						"path": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The path of a json file with username and password keys",
+						},
+						"region": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The AWS region, the region of the AWS environment when unset",
+						},
+					},
+				},
+			},
+			"ssh": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{"proxy_url"},
+				Description:   "An ssh tunnel the connections to the mongodb server go through",
+				Elem: &schema.Resource{
+					Schema: sshSchema(true),
+				},
+			},
+			"resolver_address": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "The address (host:port) of the DNS server resolving the mongodb hosts",
+			},
+			"host_overrides": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Addresses dialed instead of the mongodb hosts, keyed by host name",
+			},
+			"connect_timeout_ms": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "How long a connection can take to be opened, driver default when 0",
+			},
+			"server_selection_timeout_ms": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "How long to wait for a suitable server before failing, driver default when 0",
+			},
+			"socket_timeout_ms": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "How long a read or a write on a connection can take before failing, no timeout when 0",
+			},
+			"max_conn_idle_time_ms": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "How long a connection can stay idle in the pool before being closed, no limit when 0",
+			},
+			"heartbeat_frequency_ms": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.Any(validation.IntInSlice([]int{0}), validation.IntAtLeast(500)),
+				Description:  "How often the topology is monitored, driver default when 0",
+			},
+			"read_preference": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "The read preference used by the read operations of the provider",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"mode": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice([]string{"primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest"}, false),
+						},
+						"tag_sets": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Schema{
+								Type: schema.TypeMap,
+								Elem: &schema.Schema{Type: schema.TypeString},
+							},
+						},
+						"max_staleness_seconds": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+					},
+				},
+			},
+			"write_concern": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "The write concern of the user and role management commands",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"w": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"j": {
+							Type:     schema.TypeBool,
+							Optional: true,
+						},
+						"wtimeout_ms": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+					},
+				},
+			},
+			"read_concern": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "",
+				ValidateFunc: validation.StringInSlice([]string{"", "local", "available", "majority", "linearizable"}, false),
+				Description:  "The read concern level of the client, server default when empty",
+			},
+			"compressors": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The wire compressors offered to the server in order of preference",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringInSlice([]string{"zlib", "snappy", "zstd"}, false),
+				},
+			},
+			"zlib_level": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      -1,
+				ValidateFunc: validation.IntBetween(-1, 9),
+				Description:  "The zlib compression level, from 0 (none) to 9 (best), zlib default when -1",
+			},
+			"zstd_level": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      6,
+				ValidateFunc: validation.IntBetween(1, 20),
+				Description:  "The zstd compression level, from 1 (fastest) to 20 (best)",
+			},
+			"app_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_APP_NAME", "terraform-provider-mongodb/"+version),
+				Description: "The application name reported to the server in the connection handshake",
+			},
+			"direct_connection": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "connect to the host directly without discovering the topology",
+			},
+			"retry_reads": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "retry the read operations once on network errors and elections, driver default when unset",
+			},
+			"retry_writes": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Description: "retry the write operations once on network errors and elections, driver default when unset",
+			},
 			"insecure_skip_verify": {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     false,
+				Deprecated:  "use tls.insecure instead",
 				Description: "ignore hostname verification",
 			},
+			"allow_invalid_hostnames": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "verify the server certificate chain but not its hostname",
+			},
+			"ocsp_endpoint_check": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "query the OCSP responder of the server certificate when no OCSP response is stapled",
+			},
+			"crl_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_CRL_FILE", ""),
+				Description: "Path to a certificate revocation list the server certificate chain is checked against",
+			},
 			"ssl": {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     false,
+				Deprecated:  "use tls.enabled instead",
 				Description: "ssl activation",
 			},
+			"tls": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{"ssl", "insecure_skip_verify"},
+				Description:   "The tls settings of the connection",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"enabled": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  true,
+						},
+						"insecure": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  false,
+						},
+						"ca": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"cert": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"key": {
+							Type:      schema.TypeString,
+							Optional:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+			"connection_uri": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_URI", ""),
+				Description: "A full mongodb connection string, host, port, ssl, srv and replica_set are ignored when set",
+			},
+			"srv": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "use the mongodb+srv:// DNS seedlist connection format",
+			},
+			"config_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_CONFIG_FILE", ""),
+				Description: "Path to a json file with connection profiles, ~/.terraform-mongodb/config.json when it exists",
+			},
+			"profile": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("MONGODB_PROFILE", "default"),
+				Description: "The profile of the config file the provider defaults are read from",
+			},
+			"wait_for_connection": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "0s",
+				ValidateFunc: validateDuration,
+				Description:  "how long to wait for the server to become reachable when the provider is configured, e.g. 10m",
+			},
+			"connection_retry_interval": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "10s",
+				ValidateFunc: validateDuration,
+				Description:  "the delay between two connection attempts while waiting for the server",
+			},
+			"fips_mode": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "restrict tls to the FIPS approved versions, cipher suites and curves, and refuse insecure_skip_verify",
+			},
+			"max_pool_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "the maximum number of connections opened to each server, driver default (100) when 0",
+			},
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "how many times the operations of the resources are retried on transient errors",
+			},
+			"retry_delay": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "1s",
+				ValidateFunc: validateDuration,
+				Description:  "the delay between two retries, e.g. 500ms or 2s",
+			},
+			"docdb_compatibility": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"cosmosdb_compatibility"},
+				Description:   "adjust the connection and the commands to Amazon DocumentDB",
+			},
+			"cosmosdb_compatibility": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"docdb_compatibility"},
+				Description:   "adjust the connection to Azure Cosmos DB for MongoDB and reject the resources it does not support",
+			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
 			"mongodb_db_user": resourceDatabaseUser(),
 			"mongodb_db_role": resourceDatabaseRole(),
+			"mongodb_user_role_binding": resourceUserRoleBinding(),
+			"mongodb_role_grant": resourceRoleGrant(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
+			"mongodb_db_role": dataSourceDatabaseRole(),
+			"mongodb_db_roles": dataSourceDatabaseRoles(),
+			"mongodb_db_user": dataSourceDatabaseUser(),
+			"mongodb_db_users": dataSourceDatabaseUsers(),
+		},
+		ConfigureProvider: configureProvider,
+
+	}
+}
+
+func validatePort(v interface{}, k string) (warnings []string, errors []error) {
+	port, err := strconv.Atoi(v.(string))
+	if err != nil || port < 1 || port > 65535 {
+		errors = append(errors, fmt.Errorf("expected %s to be a port number between 1 and 65535, got %q", k, v))
+	}
+	return warnings, errors
+}
+
+func validateDuration(v interface{}, k string) (warnings []string, errors []error) {
+	duration, err := time.ParseDuration(v.(string))
+	if err != nil || duration < 0 {
+		errors = append(errors, fmt.Errorf("expected %s to be a positive duration like 500ms or 2s, got %q", k, v))
+	}
+	return warnings, errors
+}
 
+func sshSchema(withBastion bool) map[string]*schema.Schema {
+	blockSchema := map[string]*schema.Schema{
+		"host": {
+			Type:     schema.TypeString,
+			Required: true,
+		},
+		"port": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      22,
+			ValidateFunc: validation.IsPortNumber,
+		},
+		"user": {
+			Type:     schema.TypeString,
+			Required: true,
+		},
+		"password": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"private_key": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"private_key_password": {
+			Type:      schema.TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"host_key": {
+			Type:     schema.TypeString,
+			Optional: true,
 		},
-		ConfigureContextFunc: providerConfigure,
+	}
+	if withBastion {
+		blockSchema["bastion"] = &schema.Schema{
+			Type:     schema.TypeList,
+			Optional: true,
+			MaxItems: 1,
+			Elem: &schema.Resource{
+				Schema: sshSchema(false),
+			},
+		}
+	}
+	return blockSchema
+}
+
+func expandSSHConfig(sshList []interface{}) *SSHConfig {
+	if len(sshList) == 0 || sshList[0] == nil {
+		return nil
+	}
+	ssh := sshList[0].(map[string]interface{})
+	sshConfig := &SSHConfig{
+		Host:               ssh["host"].(string),
+		Port:               ssh["port"].(int),
+		User:               ssh["user"].(string),
+		Password:           ssh["password"].(string),
+		PrivateKey:         ssh["private_key"].(string),
+		PrivateKeyPassword: ssh["private_key_password"].(string),
+		HostKey:            ssh["host_key"].(string),
+	}
+	if bastion, ok := ssh["bastion"]; ok {
+		sshConfig.Bastion = expandSSHConfig(bastion.([]interface{}))
+	}
+	return sshConfig
+}
+
+func expandCredentialsSource(sourceList []interface{}) *CredentialsSource {
+	if len(sourceList) == 0 || sourceList[0] == nil {
+		return nil
+	}
+	source := sourceList[0].(map[string]interface{})
+	return &CredentialsSource{
+		Type:              source["type"].(string),
+		SecretID:          source["secret_id"].(string),
+		UsernameParameter: source["username_parameter"].(string),
+		PasswordParameter: source["password_parameter"].(string),
+		Path:              source["path"].(string),
+		Region:            source["region"].(string),
+	}
+}
 
+/*
+when the configuration depends on values only known at apply, e.g. the
+address of an instance created in the same run, the resources are deferred
+when terraform supports it, otherwise the provider does not connect and the
+reads keep the state until the configuration is known
+*/
+func configureProvider(ctx context.Context, req schema.ConfigureProviderRequest, resp *schema.ConfigureProviderResponse) {
+	if !req.ResourceData.GetRawConfig().IsWhollyKnown() {
+		tflog.Debug(ctx, "the provider configuration is unknown, not connecting to mongodb")
+		if req.DeferralAllowed {
+			resp.Deferred = &schema.Deferred{Reason: schema.DeferredReasonProviderConfigUnknown}
+			return
+		}
+		resp.Meta = &ProviderMeta{Config: &ClientConfig{}}
+		return
 	}
+	meta, diags := providerConfigure(ctx, req.ResourceData)
+	resp.Meta, resp.Diagnostics = meta, scrubDiagnostics(diags, providerSecrets(req.ResourceData)...)
 }
 
-func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
-	var diags diag.Diagnostics
+/*
+the password of credentials_source is not in the provider block, the
+diagnostics are scrubbed of it once it is fetched
+*/
+func providerConfigure(ctx context.Context, d *schema.ResourceData) (meta interface{}, diags diag.Diagnostics) {
+	var fetchedPassword string
+	defer func() {
+		diags = scrubDiagnostics(diags, fetchedPassword)
+	}()
+
+	profile, err := loadProfile(d.Get("config_file").(string), d.Get("profile").(string))
+	if err != nil {
+		return nil, diag.Errorf("%s", err)
+	}
+	if err := applyProfile(d, Provider("").Schema, profile); err != nil {
+		return nil, diag.Errorf("%s", err)
+	}
 
 	clientConfig := ClientConfig{
 		Host:     d.Get("host").(string),
@@ -87,10 +710,171 @@ func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}
 		Username: d.Get("username").(string),
 		Password: d.Get("password").(string),
 		DB:       d.Get("auth_database").(string),
+		AuthSource:         d.Get("auth_source").(string),
 		Ssl:      d.Get("ssl").(bool),
 		ReplicaSet:      d.Get("replica_set").(string),
 		Certificate:       d.Get("certificate").(string),
 		InsecureSkipVerify: d.Get("insecure_skip_verify").(bool),
+		AllowInvalidHostnames: d.Get("allow_invalid_hostnames").(bool),
+		DisableOCSPEndpointCheck: !d.Get("ocsp_endpoint_check").(bool),
+		CrlFile:            d.Get("crl_file").(string),
+		Srv:                d.Get("srv").(bool),
+		SocketPath:         d.Get("socket_path").(string),
+		ConnectionURI:      d.Get("connection_uri").(string),
+		AuthMechanism:      d.Get("auth_mechanism").(string),
+		ClientCertificate:  d.Get("client_certificate").(string),
+		ClientKey:          d.Get("client_key").(string),
+		CaFile:             d.Get("ca_file").(string),
+		CertFile:           d.Get("cert_file").(string),
+		KeyFile:            d.Get("key_file").(string),
+		KeyPassword:        d.Get("key_password").(string),
+		Pkcs12File:         d.Get("pkcs12_file").(string),
+		Pkcs12Password:     d.Get("pkcs12_password").(string),
+		AwsSessionToken:    d.Get("aws_session_token").(string),
+		GssapiServiceName:  d.Get("gssapi_service_name").(string),
+		GssapiServiceRealm: d.Get("gssapi_service_realm").(string),
+		GssapiCanonicalizeHostName: d.Get("gssapi_canonicalize_host_name").(bool),
+		OidcTokenFile:      d.Get("oidc_token_file").(string),
+		OidcEnvironment:    d.Get("oidc_environment").(string),
+		OidcTokenResource:  d.Get("oidc_token_resource").(string),
+		ProxyURL:           d.Get("proxy_url").(string),
+		ConnectTimeoutMS:   d.Get("connect_timeout_ms").(int),
+		ServerSelectionTimeoutMS: d.Get("server_selection_timeout_ms").(int),
+		SocketTimeoutMS:    d.Get("socket_timeout_ms").(int),
+		ReadConcern:        d.Get("read_concern").(string),
+		ZlibLevel:          d.Get("zlib_level").(int),
+		ZstdLevel:          d.Get("zstd_level").(int),
+		AppName:            d.Get("app_name").(string),
+		DirectConnection:   d.Get("direct_connection").(bool),
+		MaxConnIdleTimeMS:  d.Get("max_conn_idle_time_ms").(int),
+		HeartbeatFrequencyMS: d.Get("heartbeat_frequency_ms").(int),
+		ResolverAddress:    d.Get("resolver_address").(string),
+		DocDBCompatibility: d.Get("docdb_compatibility").(bool),
+		CosmosDBCompatibility: d.Get("cosmosdb_compatibility").(bool),
+		MaxRetries:         d.Get("max_retries").(int),
+		MaxPoolSize:        d.Get("max_pool_size").(int),
+		FipsMode:           d.Get("fips_mode").(bool),
+	}
+
+	retryDelay, err := time.ParseDuration(d.Get("retry_delay").(string))
+	if err != nil {
+		return nil, diag.Errorf("invalid retry_delay : %s", err)
+	}
+	clientConfig.RetryDelay = retryDelay
+	if clientConfig.WaitForConnection, err = time.ParseDuration(d.Get("wait_for_connection").(string)); err != nil {
+		return nil, diag.Errorf("invalid wait_for_connection : %s", err)
+	}
+	if clientConfig.ConnectionRetryInterval, err = time.ParseDuration(d.Get("connection_retry_interval").(string)); err != nil {
+		return nil, diag.Errorf("invalid connection_retry_interval : %s", err)
+	}
+
+	// GetOk can not tell an explicit false from an unset value
+	if retryReads, ok := d.GetOkExists("retry_reads"); ok {
+		value := retryReads.(bool)
+		clientConfig.RetryReads = &value
+	}
+	if retryWrites, ok := d.GetOkExists("retry_writes"); ok {
+		value := retryWrites.(bool)
+		clientConfig.RetryWrites = &value
+	}
+	for _, host := range d.Get("hosts").([]interface{}) {
+		clientConfig.Hosts = append(clientConfig.Hosts, host.(string))
+	}
+	for _, compressor := range d.Get("compressors").([]interface{}) {
+		clientConfig.Compressors = append(clientConfig.Compressors, compressor.(string))
+	}
+	for host, address := range d.Get("host_overrides").(map[string]interface{}) {
+		if clientConfig.HostOverrides == nil {
+			clientConfig.HostOverrides = map[string]string{}
+		}
+		clientConfig.HostOverrides[host] = address.(string)
+	}
+
+	if readPreferences := d.Get("read_preference").([]interface{}); len(readPreferences) != 0 && readPreferences[0] != nil {
+		readPreference := readPreferences[0].(map[string]interface{})
+		clientConfig.ReadPreference = &ReadPreference{
+			Mode:                readPreference["mode"].(string),
+			MaxStalenessSeconds: readPreference["max_staleness_seconds"].(int),
+		}
+		for _, tagSet := range readPreference["tag_sets"].([]interface{}) {
+			tags := map[string]string{}
+			if tagSet != nil {
+				for key, value := range tagSet.(map[string]interface{}) {
+					tags[key] = value.(string)
+				}
+			}
+			clientConfig.ReadPreference.TagSets = append(clientConfig.ReadPreference.TagSets, tags)
+		}
+	}
+
+	if writeConcerns := d.Get("write_concern").([]interface{}); len(writeConcerns) != 0 && writeConcerns[0] != nil {
+		writeConcern := writeConcerns[0].(map[string]interface{})
+		clientConfig.WriteConcern = &WriteConcern{
+			W:          writeConcern["w"].(string),
+			J:          writeConcern["j"].(bool),
+			WTimeoutMS: writeConcern["wtimeout_ms"].(int),
+		}
+	}
+
+	clientConfig.SSH = expandSSHConfig(d.Get("ssh").([]interface{}))
+	clientConfig.PasswordPolicy = expandPasswordPolicy(d.Get("password_policy").([]interface{}))
+	if source := expandCredentialsSource(d.Get("credentials_source").([]interface{})); source != nil {
+		credentials, err := source.credentials(ctx)
+		if err != nil {
+			return nil, diag.Errorf("Error fetching the credentials from %s : %s", source.Type, err)
+		}
+		if credentials.Username != "" {
+			clientConfig.Username = credentials.Username
+		}
+		clientConfig.Password = credentials.Password
+		fetchedPassword = credentials.Password
+	}
+	// ssl and insecure_skip_verify are the deprecated aliases of the tls block
+	if tlsList := d.Get("tls").([]interface{}); len(tlsList) != 0 && tlsList[0] != nil {
+		tlsBlock := tlsList[0].(map[string]interface{})
+		clientConfig.Ssl = tlsBlock["enabled"].(bool)
+		clientConfig.InsecureSkipVerify = tlsBlock["insecure"].(bool)
+		if ca := tlsBlock["ca"].(string); ca != "" {
+			clientConfig.Certificate = ca
+		}
+		if cert := tlsBlock["cert"].(string); cert != "" {
+			clientConfig.ClientCertificate = cert
+		}
+		if key := tlsBlock["key"].(string); key != "" {
+			clientConfig.ClientKey = key
+		}
+	}
+
+	if clientConfig.DocDBCompatibility {
+		if err := clientConfig.applyDocDBCompatibility(); err != nil {
+			return nil, diag.Errorf("%s", err)
+		}
+	}
+
+	if clientConfig.CosmosDBCompatibility {
+		clientConfig.applyCosmosDBCompatibility()
+	}
+
+	if clientConfig.FipsMode {
+		if err := clientConfig.applyFipsMode(); err != nil {
+			return nil, diag.Errorf("%s", err)
+		}
+		if !fips140.Enabled() {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "The Go FIPS 140-3 module is not enabled",
+				Detail:   "fips_mode restricts the tls configuration, run terraform with GODEBUG=fips140=on so the provider also uses the validated cryptographic module",
+			})
+		}
+	}
+
+	key, err := clientConfig.poolKey()
+	if err != nil {
+		return nil, diag.Errorf("Error initializing Mongo connection %s", err)
+	}
+	if client, ok := pooledClient(key); ok {
+		tflog.Debug(ctx, "reusing the mongodb client of an identical provider configuration")
+		return &ProviderMeta{Client: client, Config: &clientConfig},diags
 	}
 
 	client, err := clientConfig.MongoClient()
@@ -98,16 +882,96 @@ func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}
 	if err != nil {
 		return nil, diag.Errorf("Error initializing Mongo connection %s", err)
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	var timeout = 10 * time.Second
+	if selectionTimeout := time.Duration(clientConfig.ServerSelectionTimeoutMS) * time.Millisecond; selectionTimeout > timeout {
+		timeout = selectionTimeout
+	}
+	tflog.Debug(ctx, "connecting to mongodb", map[string]interface{}{
+		"uri":            clientConfig.redactedURI(),
+		"auth_mechanism": clientConfig.AuthMechanism,
+		"auth_source":    clientConfig.authSource(),
+		"tls":            clientConfig.Ssl || clientConfig.hasTLSConfig(),
+	})
+	connectCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	err = client.Connect(ctx)
+	err = client.Connect(connectCtx)
 	if err != nil {
-		return nil, diag.Errorf("Error connecting to Mongo server %s", err)
+		return nil, connectionDiagnostics(err)
 	}
-	err = client.Ping(ctx,nil)
+	err = waitForConnection(ctx, client, timeout, clientConfig.WaitForConnection, clientConfig.ConnectionRetryInterval)
 	if err != nil {
-		return nil, diag.Errorf("Error connecting to Mongo server %s", err)
+		_ = client.Disconnect(context.Background())
+		return nil, connectionDiagnostics(err)
 	}
-	return client,diags
+	client = registerClient(ctx, key, client)
+	tflog.Debug(ctx, "connected to mongodb")
+	return &ProviderMeta{Client: client, Config: &clientConfig},diags
 }
 
+
+/*
+with wait_for_connection the ping is retried until it succeeds or the wait
+is over, e.g. for a cluster created earlier in the same apply
+*/
+func waitForConnection(ctx context.Context, client *mongo.Client, pingTimeout time.Duration, wait time.Duration, interval time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := client.Ping(pingCtx, nil)
+		cancel()
+		if err == nil || time.Now().Add(interval).After(deadline) {
+			return err
+		}
+		tflog.Info(ctx, "mongodb is not reachable yet, retrying", map[string]interface{}{
+			"error":    scrubSecrets(err.Error()),
+			"interval": interval.String(),
+		})
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+	}
+}
+
+/*
+the authentication and tls errors only surface in the server selection error
+of the ping, they get a summary and a hint of the attributes to check
+*/
+func connectionDiagnostics(err error) diag.Diagnostics {
+	message := err.Error()
+	summary := "Error connecting to Mongo server"
+	hint := "check host, port, replica_set, the network path to the server and server_selection_timeout_ms"
+	switch {
+	case isAuthenticationError(err):
+		summary = "Authentication to the Mongo server failed"
+		hint = "check username, password, auth_source and auth_mechanism"
+	case strings.Contains(message, "x509:") || strings.Contains(message, "tls:"):
+		summary = "TLS handshake with the Mongo server failed"
+		hint = "check the CA set in tls.ca, certificate or ca_file, the client certificate and allow_invalid_hostnames"
+	}
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  summary,
+		Detail:   fmt.Sprintf("%s, %s : %s", summary, hint, message),
+	}}
+}
+
+func isAuthenticationError(err error) bool {
+	return strings.Contains(err.Error(), "auth error") || strings.Contains(err.Error(), "AuthenticationFailed")
+}
+
+/*
+cosmos db for mongodb has no custom roles, the diagnostic lists what can be
+managed there instead of the opaque command error
+*/
+func cosmosDBUnsupported(meta *ProviderMeta, resource string) diag.Diagnostics {
+	if !meta.Config.CosmosDBCompatibility {
+		return nil
+	}
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf("%s is not supported by Azure Cosmos DB for MongoDB", resource),
+		Detail:   "Azure Cosmos DB for MongoDB does not support createRole nor rolesInfo, only mongodb_db_user and the mongodb_temporary_user ephemeral resource can be used with cosmosdb_compatibility",
+	}}
+}
@@ -4,37 +4,51 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/mitchellh/mapstructure"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 	"strings"
+	"time"
 )
 
 func resourceDatabaseRole() *schema.Resource {
 	return &schema.Resource{
-		CreateContext: resourceDatabaseRoleCreate,
-		ReadContext:   resourceDatabaseRoleRead,
-		UpdateContext: resourceDatabaseRoleUpdate,
-		DeleteContext: resourceDatabaseRoleDelete,
+		CreateContext: tracedOperation("mongodb_db_role.create", resourceDatabaseRoleCreate),
+		ReadContext:   tracedOperation("mongodb_db_role.read", resourceDatabaseRoleRead),
+		UpdateContext: tracedOperation("mongodb_db_role.update", resourceDatabaseRoleUpdate),
+		DeleteContext: tracedOperation("mongodb_db_role.delete", resourceDatabaseRoleDelete),
 		Importer: &schema.ResourceImporter{
-			StateContext: schema.ImportStatePassthroughContext,
+			StateContext: resourceDatabaseRoleImport,
+		},
+		Identity:      databaseNameIdentity("database"),
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			resourceDatabaseRoleStateUpgraderV0(),
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Read:   schema.DefaultTimeout(2 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
 		},
 		Schema: map[string]*schema.Schema{
 			"database": {
-				Type:     schema.TypeString,
-				Optional: true,
-				Default: "admin",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "admin",
+				ForceNew:    true,
+				Description: "The database of the role, changing it replaces the role",
 			},
 			"name": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateRoleName,
 			},
 			"privilege": {
 				Type:     schema.TypeSet,
 				Optional: true,
-				MaxItems: 10,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 
@@ -46,12 +60,24 @@ func resourceDatabaseRole() *schema.Resource {
 							Type:     schema.TypeString,
 							Optional: true,
 						},
+						"cluster": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "Grant the actions on the cluster, e.g. addShard or serverStatus, instead of db and collection",
+						},
+						"system_buckets": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Grant the actions on the buckets of the time series collection, instead of collection. Requires MongoDB 5.0 or later",
+						},
 
 						"actions": {
 							Type:     schema.TypeList,
 							Optional: true,
 							Elem: &schema.Schema{
-								Type: schema.TypeString,
+								Type:         schema.TypeString,
+								ValidateFunc: validatePrivilegeAction,
 							},
 						},
 					},
@@ -60,12 +86,12 @@ func resourceDatabaseRole() *schema.Resource {
 			"inherited_role": {
 				Type:     schema.TypeSet,
 				Optional: true,
-				MaxItems: 2,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"db": {
-							Type:     schema.TypeString,
-							Optional: true,
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "The database of the inherited role, the database of the role when it is not set",
 						},
 						"role": {
 							Type:     schema.TypeString,
@@ -79,7 +105,11 @@ func resourceDatabaseRole() *schema.Resource {
 }
 
 func resourceDatabaseRoleCreate(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
-	var client = i.(*mongo.Client)
+	if diags := cosmosDBUnsupported(i.(*ProviderMeta), "mongodb_db_role"); diags != nil {
+		return diags
+	}
+	var meta = i.(*ProviderMeta)
+	var client = meta.Client
 	var role = data.Get("name").(string)
 	var database = data.Get("database").(string)
 	var roleList []Role
@@ -92,13 +122,19 @@ func resourceDatabaseRoleCreate(ctx context.Context, data *schema.ResourceData,
 	if roleMapErr != nil {
 		return diag.Errorf("Error decoding map : %s ", roleMapErr)
 	}
+	roleList = defaultRoleDatabase(roleList, database)
 	privMapErr := mapstructure.Decode(privilege, &privileges)
 	if privMapErr != nil {
 		return diag.Errorf("Error decoding map : %s ", privMapErr)
 	}
+	if diags := validatePrivileges(privileges); diags != nil {
+		return diags
+	}
 
 
-	err := createRole(client, role, roleList, privileges, database)
+	err := meta.retry(ctx, func() error {
+		return createRole(ctx, client, role, roleList, privileges, database)
+	})
 
 	if err != nil {
 		return diag.Errorf("Could not create the role : %s ", err)
@@ -110,16 +146,16 @@ func resourceDatabaseRoleCreate(ctx context.Context, data *schema.ResourceData,
 }
 
 func resourceDatabaseRoleDelete(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
-	var client = i.(*mongo.Client)
+	var meta = i.(*ProviderMeta)
 	var stateId = data.State().ID
 	id, errEncoding := hex.DecodeString(stateId)
 	if errEncoding != nil {
 		return diag.Errorf("ID mismatch %s", errEncoding)
 	}
 
-	adminDB := client.Database("admin")
-	Users := adminDB.Collection("system.roles")
-	_, err := Users.DeleteOne(ctx, bson.M{"_id": string(id) })
+	err := meta.retry(ctx, func() error {
+		return deleteRole(ctx, meta, string(id))
+	})
 	if err != nil {
 		return diag.Errorf("%s",err)
 	}
@@ -129,7 +165,11 @@ func resourceDatabaseRoleDelete(ctx context.Context, data *schema.ResourceData,
 }
 
 func resourceDatabaseRoleUpdate(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
-	var client = i.(*mongo.Client)
+	var meta = i.(*ProviderMeta)
+	if diags := cosmosDBUnsupported(meta, "mongodb_db_role"); diags != nil {
+		return diags
+	}
+	var client = meta.Client
 	var role = data.Get("name").(string)
 	var database = data.Get("database").(string)
 	var stateId = data.State().ID
@@ -137,12 +177,6 @@ func resourceDatabaseRoleUpdate(ctx context.Context, data *schema.ResourceData,
 	if errEncoding != nil {
 		return diag.Errorf("ID mismatch %s", errEncoding)
 	}
-	adminDB := client.Database("admin")
-	Users := adminDB.Collection("system.roles")
-	_, err := Users.DeleteOne(ctx, bson.M{"_id": string(id) })
-	if err != nil {
-		return diag.Errorf("%s",err)
-	}
 	var roleList []Role
 	var privileges []PrivilegeDto
 
@@ -153,15 +187,46 @@ func resourceDatabaseRoleUpdate(ctx context.Context, data *schema.ResourceData,
 	if roleMapErr != nil {
 		return diag.Errorf("Error decoding map : %s ", roleMapErr)
 	}
+	roleList = defaultRoleDatabase(roleList, database)
 	privMapErr := mapstructure.Decode(privilege, &privileges)
 	if privMapErr != nil {
 		return diag.Errorf("Error decoding map : %s ", privMapErr)
 	}
+	if diags := validatePrivileges(privileges); diags != nil {
+		return diags
+	}
+
+	/* database forces a new role */
+	if !data.HasChange("name") && meta.Config.DocDBCompatibility {
+		/* documentdb does not know the commands granting privileges to roles */
+		err := meta.retry(ctx, func() error {
+			return updateRole(ctx, client, role, roleList, privileges, database)
+		})
+		if err != nil {
+			return diag.Errorf("Could not update the role : %s ", err)
+		}
+		return resourceDatabaseRoleRead(ctx, data, i)
+	}
+	if !data.HasChange("name") {
+		if diags := updateRoleInPlace(ctx, meta, data, role, database); diags != nil {
+			return diags
+		}
+		return resourceDatabaseRoleRead(ctx, data, i)
+	}
 
-	err2 := createRole(client, role, roleList, privileges, database)
+	/* a renamed role is a new role, the old one is dropped */
+	err := meta.retry(ctx, func() error {
+		return deleteRole(ctx, meta, string(id))
+	})
+	if err != nil {
+		return diag.Errorf("%s",err)
+	}
+	err2 := meta.retry(ctx, func() error {
+		return createRole(ctx, client, role, roleList, privileges, database)
+	})
 
 	if err2 != nil {
-		return diag.Errorf("Could not create the role  :  %s ", err)
+		return diag.Errorf("Could not create the role  :  %s ", err2)
 	}
 	str := database+"."+role
 	hx := hex.EncodeToString([]byte(str))
@@ -173,47 +238,232 @@ func resourceDatabaseRoleUpdate(ctx context.Context, data *schema.ResourceData,
 
 func resourceDatabaseRoleRead(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
-	var client = i.(*mongo.Client)
+	if diags := cosmosDBUnsupported(i.(*ProviderMeta), "mongodb_db_role"); diags != nil {
+		return diags
+	}
+	var meta = i.(*ProviderMeta)
+	if meta.Client == nil {
+		// the provider configuration is unknown during this plan
+		return nil
+	}
+	var client = meta.Client
 	stateID := data.State().ID
 	roleName, database , err := resourceDatabaseRoleParseId(stateID)
 	if err != nil {
 		return diag.Errorf("%s",err)
 	}
-	result , decodeError := getRole(client,roleName,database)
+	var result SingleResultGetRole
+	decodeError := meta.retry(ctx, func() error {
+		var err error
+		result, err = getRole(ctx, client,roleName,database)
+		return err
+	})
 	if decodeError != nil {
-		return diag.Errorf("Error decoding role : %s ", err)
+		return diag.Errorf("Error decoding role : %s ", decodeError)
 	}
 	if len(result.Roles) == 0 {
-		return diag.Errorf("Role does not exist")
+		/* dropped outside of terraform, it is planned for creation again */
+		tflog.Warn(ctx, "the role does not exist anymore, removing it from the state", map[string]interface{}{
+			"role":     roleName,
+			"database": database,
+		})
+		data.SetId("")
+		return nil
 	}
-	inheritedRoles := make([]interface{}, len(result.Roles[0].InheritedRoles))
+	/* the roles of the role, inheritedRoles also holds the roles they inherit */
+	inheritedRoles := make([]interface{}, len(result.Roles[0].Roles))
+	configuredRoles := data.Get("inherited_role").(*schema.Set)
 
-	for i, s := range result.Roles[0].InheritedRoles {
+	for i, s := range result.Roles[0].Roles {
+		db := s.Db
+		/* a role configured without db is returned with the database of the role */
+		if db == database && configuredRoles.Contains(map[string]interface{}{"db": "", "role": s.Role}) {
+			db = ""
+		}
 		inheritedRoles[i] = map[string]interface{}{
-			"db": s.Db,
+			"db": db,
 			"role": s.Role,
 		}
 	}
 	data.Set("inherited_role", inheritedRoles)
-	privileges := make([]interface{}, len(result.Roles[0].Privileges))
-
-	for i, s := range result.Roles[0].Privileges {
-		privileges[i] = map[string]interface{}{
-			"db": s.Resource.Db,
-			"collection": s.Resource.Collection,
-			"actions": s.Actions,
-		}
-	}
-	data.Set("privilege", privileges)
+	data.Set("privilege", flattenPrivileges(result.Roles[0].Privileges))
 
 	data.Set("database", database)
 	data.Set("name", roleName)
 
 	data.SetId(stateID)
+	if err := setDatabaseNameIdentity(data, "database", database, roleName); err != nil {
+		return diag.Errorf("Error setting the identity of the role : %s ", err)
+	}
 	diags = nil
 	return diags
 }
 
+/*
+the cluster resource can not hold a database nor a collection, the buckets
+of a time series collection can not hold a collection
+*/
+func validatePrivileges(privileges []PrivilegeDto) diag.Diagnostics {
+	for _, privilege := range privileges {
+		if privilege.Cluster && (privilege.Db != "" || privilege.Collection != "" || privilege.SystemBuckets != "") {
+			return diag.Errorf("the privilege with cluster = true can not set db, collection nor system_buckets, found db %q and collection %q", privilege.Db, privilege.Collection)
+		}
+		if privilege.SystemBuckets != "" && privilege.Collection != "" {
+			return diag.Errorf("the privilege with system_buckets %q can not set collection, found %q", privilege.SystemBuckets, privilege.Collection)
+		}
+	}
+	return nil
+}
+
+/*
+the import id is database.roleName or the hex encoded id of the state,
+which never holds a dot
+*/
+func resourceDatabaseRoleImport(ctx context.Context, data *schema.ResourceData, i interface{}) ([]*schema.ResourceData, error) {
+	if err := setIdFromIdentity(data, "database"); err != nil {
+		return nil, err
+	}
+	if strings.Contains(data.Id(), ".") {
+		data.SetId(hex.EncodeToString([]byte(data.Id())))
+	}
+	if _, _, err := resourceDatabaseRoleParseId(data.Id()); err != nil {
+		return nil, fmt.Errorf("unexpected format of ID (%s), expected database.roleName", data.Id())
+	}
+	return []*schema.ResourceData{data}, nil
+}
+
+/*
+only the privileges and the inherited roles which changed are granted and
+revoked, the grants come first so the users keep their access
+*/
+func updateRoleInPlace(ctx context.Context, meta *ProviderMeta, data *schema.ResourceData, role string, database string) diag.Diagnostics {
+	var oldPrivileges, newPrivileges []PrivilegeDto
+	var oldRoles, newRoles []Role
+	oldPrivilege, newPrivilege := data.GetChange("privilege")
+	oldRole, newRole := data.GetChange("inherited_role")
+	for _, err := range []error{
+		mapstructure.Decode(oldPrivilege.(*schema.Set).List(), &oldPrivileges),
+		mapstructure.Decode(newPrivilege.(*schema.Set).List(), &newPrivileges),
+		mapstructure.Decode(oldRole.(*schema.Set).List(), &oldRoles),
+		mapstructure.Decode(newRole.(*schema.Set).List(), &newRoles),
+	} {
+		if err != nil {
+			return diag.Errorf("Error decoding map : %s ", err)
+		}
+	}
+	grantedPrivileges, revokedPrivileges := privilegeDifference(oldPrivileges, newPrivileges)
+	grantedRoles, revokedRoles := roleDifference(defaultRoleDatabase(oldRoles, database), defaultRoleDatabase(newRoles, database))
+	client := meta.Client
+	err := meta.retry(ctx, func() error {
+		return updateRolePrivileges(ctx, client, "grantPrivilegesToRole", role, grantedPrivileges, database)
+	})
+	if err == nil {
+		err = meta.retry(ctx, func() error {
+			return updateRoleRoles(ctx, client, "grantRolesToRole", role, grantedRoles, database)
+		})
+	}
+	if err == nil {
+		err = meta.retry(ctx, func() error {
+			return updateRolePrivileges(ctx, client, "revokePrivilegesFromRole", role, revokedPrivileges, database)
+		})
+	}
+	if err == nil {
+		err = meta.retry(ctx, func() error {
+			return updateRoleRoles(ctx, client, "revokeRolesFromRole", role, revokedRoles, database)
+		})
+	}
+	if err != nil {
+		return diag.Errorf("Could not update the role : %s ", err)
+	}
+	return nil
+}
+
+/*
+the server merges the actions of the privileges on the same resource, the
+actions are compared by resource so a revoke never removes an action which
+is still configured
+*/
+func privilegeDifference(from []PrivilegeDto, to []PrivilegeDto) ([]PrivilegeDto, []PrivilegeDto) {
+	return missingActions(to, from), missingActions(from, to)
+}
+
+/*
+the actions of privileges which are not in others, by resource
+*/
+func missingActions(privileges []PrivilegeDto, others []PrivilegeDto) []PrivilegeDto {
+	resourceKey := func(privilege PrivilegeDto) string {
+		return fmt.Sprintf("%q %q %t %q", privilege.Db, privilege.Collection, privilege.Cluster, privilege.SystemBuckets)
+	}
+	known := map[string]bool{}
+	for _, other := range others {
+		for _, action := range other.Actions {
+			known[resourceKey(other)+" "+action] = true
+		}
+	}
+	var missing []PrivilegeDto
+	for _, privilege := range privileges {
+		var actions []string
+		for _, action := range privilege.Actions {
+			key := resourceKey(privilege) + " " + action
+			if !known[key] {
+				known[key] = true
+				actions = append(actions, action)
+			}
+		}
+		if len(actions) != 0 {
+			missing = append(missing, PrivilegeDto{Db: privilege.Db, Collection: privilege.Collection, Cluster: privilege.Cluster, SystemBuckets: privilege.SystemBuckets, Actions: actions})
+		}
+	}
+	return missing
+}
+
+/*
+the role names are checked before the apply, the id is database.roleName
+so a name starting with a dot would not be read back
+*/
+func validateRoleName(v interface{}, k string) (warnings []string, errors []error) {
+	name := v.(string)
+	switch {
+	case name == "":
+		errors = append(errors, fmt.Errorf("expected %s to not be empty", k))
+	case len(name) > 1024:
+		errors = append(errors, fmt.Errorf("expected %s to be at most 1024 bytes, got %d", k, len(name)))
+	case strings.ContainsRune(name, 0):
+		errors = append(errors, fmt.Errorf("expected %s to not contain a null byte", k))
+	case strings.HasPrefix(name, "$"), strings.HasPrefix(name, "."):
+		errors = append(errors, fmt.Errorf("expected %s to not start with $ nor a dot, got %q", k, name))
+	case strings.TrimSpace(name) != name:
+		errors = append(errors, fmt.Errorf("expected %s to not start or end with whitespace, got %q", k, name))
+	}
+	return warnings, errors
+}
+
+/*
+an inherited role without db is a role of the database of the role
+*/
+func defaultRoleDatabase(roles []Role, database string) []Role {
+	for i := range roles {
+		if roles[i].Db == "" {
+			roles[i].Db = database
+		}
+	}
+	return roles
+}
+
+func flattenPrivileges(rolePrivileges []RolePrivilege) []interface{} {
+	privileges := make([]interface{}, len(rolePrivileges))
+	for i, s := range rolePrivileges {
+		privileges[i] = map[string]interface{}{
+			"db":             s.Resource.Db,
+			"collection":     s.Resource.Collection,
+			"cluster":        s.Resource.Cluster,
+			"system_buckets": s.Resource.SystemBuckets,
+			"actions":        s.Actions,
+		}
+	}
+	return privileges
+}
+
 func resourceDatabaseRoleParseId(id string) (string, string, error) {
 	result , errEncoding := hex.DecodeString(id)
 
@@ -231,3 +481,23 @@ func resourceDatabaseRoleParseId(id string) (string, string, error) {
 	return roleName , database , nil
 }
 
+
+/*
+the role is dropped with dropRole in its database, unlike a write to
+admin.system.roles it invalidates the auth cache and works through mongos,
+id is database.roleName
+*/
+func deleteRole(ctx context.Context, meta *ProviderMeta, id string) error {
+	parts := strings.SplitN(id, ".", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("unexpected format of ID (%s), expected database.roleName", id)
+	}
+	tflog.Debug(ctx, "deleting mongodb role", map[string]interface{}{"role": id})
+	db := meta.Client.Database(parts[0])
+	err := runCommand(ctx, db, withWriteConcern(db, bson.D{{Key: "dropRole", Value: parts[1]}})).Err()
+	if isRoleNotFound(err) {
+		/* already dropped outside of terraform */
+		return nil
+	}
+	return err
+}
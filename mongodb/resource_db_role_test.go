@@ -0,0 +1,102 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/mitchellh/mapstructure"
+)
+
+func TestResourceDatabaseRoleLargeRole(t *testing.T) {
+	var privileges []interface{}
+	for i := 0; i < 12; i++ {
+		privileges = append(privileges, map[string]interface{}{
+			"db":         "test_db",
+			"collection": fmt.Sprintf("collection_%d", i),
+			"actions":    []interface{}{"find", "insert"},
+		})
+	}
+	var inheritedRoles []interface{}
+	for i := 0; i < 3; i++ {
+		inheritedRoles = append(inheritedRoles, map[string]interface{}{
+			"db":   "test_db",
+			"role": fmt.Sprintf("role_%d", i),
+		})
+	}
+	raw := map[string]interface{}{
+		"database":       "test_db",
+		"name":           "large_role",
+		"privilege":      privileges,
+		"inherited_role": inheritedRoles,
+	}
+
+	resource := resourceDatabaseRole()
+	if diags := resource.Validate(terraform.NewResourceConfigRaw(raw)); diags.HasError() {
+		t.Fatalf("the large role is not valid : %v", diags)
+	}
+	data := schema.TestResourceDataRaw(t, resource.Schema, raw)
+
+	var privilegeList []PrivilegeDto
+	if err := mapstructure.Decode(data.Get("privilege").(*schema.Set).List(), &privilegeList); err != nil {
+		t.Fatal(err)
+	}
+	var roleList []Role
+	if err := mapstructure.Decode(data.Get("inherited_role").(*schema.Set).List(), &roleList); err != nil {
+		t.Fatal(err)
+	}
+	if len(privilegeList) != 12 || len(roleList) != 3 {
+		t.Fatalf("expected 12 privileges and 3 inherited roles, got %d and %d", len(privilegeList), len(roleList))
+	}
+	if diags := validatePrivileges(privilegeList); diags != nil {
+		t.Fatalf("the privileges are not valid : %v", diags)
+	}
+
+	/* the privileges as the server returns them */
+	rolePrivileges := make([]RolePrivilege, len(privilegeList))
+	for i, privilege := range privilegeList {
+		rolePrivileges[i].Resource.Db = privilege.Db
+		rolePrivileges[i].Resource.Collection = privilege.Collection
+		rolePrivileges[i].Actions = privilege.Actions
+	}
+	expected := data.Get("privilege").(*schema.Set)
+	if err := data.Set("privilege", flattenPrivileges(rolePrivileges)); err != nil {
+		t.Fatal(err)
+	}
+	if err := data.Set("inherited_role", flattenRoles(roleList)); err != nil {
+		t.Fatal(err)
+	}
+	if !expected.Equal(data.Get("privilege")) {
+		t.Fatalf("the privileges do not round trip : %v", data.Get("privilege"))
+	}
+	if data.Get("inherited_role").(*schema.Set).Len() != 3 {
+		t.Fatalf("the inherited roles do not round trip : %v", data.Get("inherited_role"))
+	}
+}
+
+func TestResourceDatabaseRoleDatabaseChangeReplaces(t *testing.T) {
+	state := &terraform.InstanceState{
+		ID: "61646d696e2e7265706f7274696e67",
+		Attributes: map[string]string{
+			"id":       "61646d696e2e7265706f7274696e67",
+			"database": "admin",
+			"name":     "reporting",
+		},
+	}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"database": "reporting_db",
+		"name":     "reporting",
+	})
+	diff, err := resourceDatabaseRole().Diff(context.Background(), state, config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff == nil || !diff.RequiresNew() {
+		t.Fatalf("expected a change of database to replace the role, got %v", diff)
+	}
+	if attribute := diff.Attributes["database"]; attribute == nil || !attribute.RequiresNew {
+		t.Fatalf("expected the replacement to be caused by database, got %v", diff)
+	}
+}
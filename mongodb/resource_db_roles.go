@@ -0,0 +1,554 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"slices"
+	"strings"
+)
+
+/*
+the roles of a database managed as one resource, the refresh lists the
+roles of the database once instead of one rolesInfo per role
+*/
+type databaseRolesResource struct {
+	meta *ProviderMeta
+}
+
+type databaseRolesModel struct {
+	Id       types.String                 `tfsdk:"id"`
+	Database types.String                 `tfsdk:"database"`
+	Roles    map[string]databaseRolesRole `tfsdk:"roles"`
+}
+
+type databaseRolesRole struct {
+	Privileges     []databaseRolesPrivilege `tfsdk:"privileges"`
+	InheritedRoles []databaseUsersRole      `tfsdk:"inherited_roles"`
+}
+
+type databaseRolesPrivilege struct {
+	Db            types.String   `tfsdk:"db"`
+	Collection    types.String   `tfsdk:"collection"`
+	Cluster       types.Bool     `tfsdk:"cluster"`
+	SystemBuckets types.String   `tfsdk:"system_buckets"`
+	Actions       []types.String `tfsdk:"actions"`
+}
+
+func NewDatabaseRolesResource() resource.Resource {
+	return &databaseRolesResource{}
+}
+
+func (r *databaseRolesResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_db_roles"
+}
+
+func (r *databaseRolesResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "The custom roles of a database, keyed by role name",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"database": schema.StringAttribute{
+				Required:      true,
+				Description:   "The database the roles are created in",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"roles": schema.MapNestedAttribute{
+				Required:    true,
+				Description: "The roles keyed by role name",
+				Validators:  []validator.Map{roleNamesValidator{}},
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"privileges": schema.SetNestedAttribute{
+							Optional:    true,
+							Description: "The privileges of the role",
+							NestedObject: schema.NestedAttributeObject{
+								Attributes: map[string]schema.Attribute{
+									"db": schema.StringAttribute{
+										Optional:    true,
+										Description: "The database of the resource",
+									},
+									"collection": schema.StringAttribute{
+										Optional:    true,
+										Description: "The collection of the resource, every collection when it is empty",
+									},
+									"cluster": schema.BoolAttribute{
+										Optional:    true,
+										Description: "Grant the actions on the cluster instead of db and collection",
+									},
+									"system_buckets": schema.StringAttribute{
+										Optional:    true,
+										Description: "Grant the actions on the buckets of the time series collection, instead of collection",
+									},
+									"actions": schema.ListAttribute{
+										Required:    true,
+										ElementType: types.StringType,
+										Description: "The privilege actions",
+										Validators:  []validator.List{privilegeActionsValidator{}},
+									},
+								},
+							},
+						},
+						"inherited_roles": schema.SetNestedAttribute{
+							Optional:    true,
+							Description: "The roles the role inherits",
+							NestedObject: schema.NestedAttributeObject{
+								Attributes: map[string]schema.Attribute{
+									"role": schema.StringAttribute{
+										Required:    true,
+										Description: "The role name",
+									},
+									"db": schema.StringAttribute{
+										Optional:    true,
+										Description: "The database of the role, database when it is not set",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *databaseRolesResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	meta, ok := req.ProviderData.(*ProviderMeta)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected provider data", fmt.Sprintf("expected *ProviderMeta, got %T", req.ProviderData))
+		return
+	}
+	r.meta = meta
+}
+
+func (r *databaseRolesResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := tracer.Start(ctx, "mongodb_db_roles.create")
+	defer span.End()
+	var plan databaseRolesModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() || !r.supported(&resp.Diagnostics) {
+		return
+	}
+	database := plan.Database.ValueString()
+	created := databaseRolesModel{
+		Id:       types.StringValue(database),
+		Database: plan.Database,
+		Roles:    map[string]databaseRolesRole{},
+	}
+	order, err := rolesInCreationOrder(plan.Roles, database)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("roles"), "Invalid roles", err.Error())
+		return
+	}
+	for _, name := range order {
+		role := plan.Roles[name]
+		if err := role.validate(); err != nil {
+			resp.Diagnostics.AddError("Invalid role", fmt.Sprintf("%s : %s", name, err))
+			resp.Diagnostics.Append(resp.State.Set(ctx, &created)...)
+			return
+		}
+		err := r.meta.retry(ctx, func() error {
+			return createRole(ctx, r.meta.Client, name, role.roleList(database), role.privilegeList(), database)
+		})
+		if err != nil {
+			/* the roles created so far are kept in the state */
+			resp.Diagnostics.AddError("Could not create the role", fmt.Sprintf("%s : %s", name, err))
+			resp.Diagnostics.Append(resp.State.Set(ctx, &created)...)
+			return
+		}
+		created.Roles[name] = role
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &created)...)
+}
+
+func (r *databaseRolesResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := tracer.Start(ctx, "mongodb_db_roles.read")
+	defer span.End()
+	if r.meta == nil || r.meta.Client == nil {
+		// the provider configuration is unknown during this plan
+		return
+	}
+	if !r.supported(&resp.Diagnostics) {
+		return
+	}
+	var state databaseRolesModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	database := state.Database.ValueString()
+	var result SingleResultGetRole
+	err := r.meta.retry(ctx, func() error {
+		var err error
+		result, err = listRoles(ctx, r.meta.Client, database, false, true)
+		return err
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Could not list the roles", fmt.Sprintf("%s : %s", database, err))
+		return
+	}
+	existing := map[string]int{}
+	for index, role := range result.Roles {
+		existing[role.Role] = index
+	}
+	roles := map[string]databaseRolesRole{}
+	for name, role := range state.Roles {
+		index, ok := existing[name]
+		if !ok {
+			/* dropped outside of terraform, it is planned for creation again */
+			continue
+		}
+		found := result.Roles[index]
+		var privileges []PrivilegeDto
+		for _, privilege := range found.Privileges {
+			privileges = append(privileges, PrivilegeDto{
+				Db:            privilege.Resource.Db,
+				Collection:    privilege.Resource.Collection,
+				Cluster:       privilege.Resource.Cluster,
+				SystemBuckets: privilege.Resource.SystemBuckets,
+				Actions:       privilege.Actions,
+			})
+		}
+		/* the server merges and orders the actions, an equivalent configuration is kept */
+		granted, revoked := privilegeDifference(role.privilegeList(), privileges)
+		if len(granted) != 0 || len(revoked) != 0 {
+			role.Privileges = flattenDatabaseRolesPrivileges(privileges)
+		}
+		if grantedRoles, revokedRoles := roleDifference(role.roleList(database), found.Roles); len(grantedRoles) != 0 || len(revokedRoles) != 0 {
+			role.InheritedRoles = make([]databaseUsersRole, 0, len(found.Roles))
+			for _, inherited := range found.Roles {
+				role.InheritedRoles = append(role.InheritedRoles, databaseUsersRole{Role: types.StringValue(inherited.Role), Db: types.StringValue(inherited.Db)})
+			}
+		}
+		roles[name] = role
+	}
+	state.Roles = roles
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *databaseRolesResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := tracer.Start(ctx, "mongodb_db_roles.update")
+	defer span.End()
+	var plan, state databaseRolesModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() || !r.supported(&resp.Diagnostics) {
+		return
+	}
+	database := plan.Database.ValueString()
+	client := r.meta.Client
+	/* the state follows the roles which were reconciled */
+	current := make(map[string]databaseRolesRole, len(state.Roles))
+	for name, role := range state.Roles {
+		current[name] = role
+	}
+	save := func() {
+		state.Roles = current
+		resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	}
+	for _, name := range sortedNames(state.Roles) {
+		if _, ok := plan.Roles[name]; ok {
+			continue
+		}
+		err := r.meta.retry(ctx, func() error {
+			return deleteRole(ctx, r.meta, database+"."+name)
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("Could not drop the role", fmt.Sprintf("%s : %s", name, err))
+			save()
+			return
+		}
+		delete(current, name)
+	}
+	order, err := rolesInCreationOrder(plan.Roles, database)
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("roles"), "Invalid roles", err.Error())
+		return
+	}
+	for _, name := range order {
+		role := plan.Roles[name]
+		if err := role.validate(); err != nil {
+			resp.Diagnostics.AddError("Invalid role", fmt.Sprintf("%s : %s", name, err))
+			save()
+			return
+		}
+		previous, exists := state.Roles[name]
+		var err error
+		switch {
+		case !exists:
+			err = r.meta.retry(ctx, func() error {
+				return createRole(ctx, client, name, role.roleList(database), role.privilegeList(), database)
+			})
+		case r.meta.Config.DocDBCompatibility:
+			/* documentdb does not know the commands granting privileges to roles */
+			err = r.meta.retry(ctx, func() error {
+				return updateRole(ctx, client, name, role.roleList(database), role.privilegeList(), database)
+			})
+		default:
+			grantedPrivileges, revokedPrivileges := privilegeDifference(previous.privilegeList(), role.privilegeList())
+			grantedRoles, revokedRoles := roleDifference(previous.roleList(database), role.roleList(database))
+			err = r.meta.retry(ctx, func() error {
+				return updateRolePrivileges(ctx, client, "grantPrivilegesToRole", name, grantedPrivileges, database)
+			})
+			if err == nil {
+				err = r.meta.retry(ctx, func() error {
+					return updateRoleRoles(ctx, client, "grantRolesToRole", name, grantedRoles, database)
+				})
+			}
+			if err == nil {
+				err = r.meta.retry(ctx, func() error {
+					return updateRolePrivileges(ctx, client, "revokePrivilegesFromRole", name, revokedPrivileges, database)
+				})
+			}
+			if err == nil {
+				err = r.meta.retry(ctx, func() error {
+					return updateRoleRoles(ctx, client, "revokeRolesFromRole", name, revokedRoles, database)
+				})
+			}
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("Could not update the role", fmt.Sprintf("%s : %s", name, err))
+			save()
+			return
+		}
+		current[name] = role
+	}
+	state.Database = plan.Database
+	save()
+}
+
+func (r *databaseRolesResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := tracer.Start(ctx, "mongodb_db_roles.delete")
+	defer span.End()
+	var state databaseRolesModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	database := state.Database.ValueString()
+	for _, name := range sortedNames(state.Roles) {
+		err := r.meta.retry(ctx, func() error {
+			return deleteRole(ctx, r.meta, database+"."+name)
+		})
+		if err != nil {
+			resp.Diagnostics.AddError("Could not drop the role", fmt.Sprintf("%s : %s", name, err))
+			return
+		}
+	}
+}
+
+/*
+the roles inheriting each other fail during the plan, the roles holding
+unknown values are checked again during the apply
+*/
+func (r *databaseRolesResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var database types.String
+	var roles types.Map
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("database"), &database)...)
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("roles"), &roles)...)
+	if resp.Diagnostics.HasError() || database.IsUnknown() || roles.IsNull() || roles.IsUnknown() {
+		return
+	}
+	var decoded map[string]databaseRolesRole
+	if diags := roles.ElementsAs(ctx, &decoded, false); diags.HasError() {
+		return
+	}
+	if _, err := rolesInCreationOrder(decoded, database.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("roles"), "Invalid roles", err.Error())
+	}
+}
+
+/*
+the roles inherited from the same map are created first, the roles are
+otherwise created in the order of their names
+*/
+func rolesInCreationOrder(roles map[string]databaseRolesRole, database string) ([]string, error) {
+	order := make([]string, 0, len(roles))
+	created := map[string]bool{}
+	var inheriting []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		if created[name] {
+			return nil
+		}
+		if index := slices.Index(inheriting, name); index >= 0 {
+			cycle := append(slices.Clone(inheriting[index:]), name)
+			return fmt.Errorf("the roles inherit each other : %s", strings.Join(cycle, " -> "))
+		}
+		inheriting = append(inheriting, name)
+		for _, inherited := range roles[name].InheritedRoles {
+			if inherited.Role.IsUnknown() || inherited.Db.IsUnknown() {
+				continue
+			}
+			db := inherited.Db.ValueString()
+			if db == "" {
+				db = database
+			}
+			if _, ok := roles[inherited.Role.ValueString()]; !ok || db != database {
+				continue
+			}
+			if err := visit(inherited.Role.ValueString()); err != nil {
+				return err
+			}
+		}
+		inheriting = inheriting[:len(inheriting)-1]
+		created[name] = true
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range sortedNames(roles) {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+/*
+cosmos db has no custom roles
+*/
+func (r *databaseRolesResource) supported(diags *diag.Diagnostics) bool {
+	if r.meta == nil || r.meta.Config == nil || !r.meta.Config.CosmosDBCompatibility {
+		return true
+	}
+	diags.AddError("mongodb_db_roles is not supported by Azure Cosmos DB for MongoDB", "Azure Cosmos DB for MongoDB does not support createRole nor rolesInfo")
+	return false
+}
+
+func (role databaseRolesRole) privilegeList() []PrivilegeDto {
+	privileges := make([]PrivilegeDto, 0, len(role.Privileges))
+	for _, privilege := range role.Privileges {
+		actions := make([]string, 0, len(privilege.Actions))
+		for _, action := range privilege.Actions {
+			actions = append(actions, action.ValueString())
+		}
+		privileges = append(privileges, PrivilegeDto{
+			Db:            privilege.Db.ValueString(),
+			Collection:    privilege.Collection.ValueString(),
+			Cluster:       privilege.Cluster.ValueBool(),
+			SystemBuckets: privilege.SystemBuckets.ValueString(),
+			Actions:       actions,
+		})
+	}
+	return privileges
+}
+
+/*
+an inherited role without db is a role of the database
+*/
+func (role databaseRolesRole) roleList(database string) []Role {
+	roles := make([]Role, 0, len(role.InheritedRoles))
+	for _, inherited := range role.InheritedRoles {
+		db := inherited.Db.ValueString()
+		if db == "" {
+			db = database
+		}
+		roles = append(roles, Role{Role: inherited.Role.ValueString(), Db: db})
+	}
+	return roles
+}
+
+func (role databaseRolesRole) validate() error {
+	if diags := validatePrivileges(role.privilegeList()); diags.HasError() {
+		return fmt.Errorf("%s", diags[0].Summary)
+	}
+	return nil
+}
+
+func flattenDatabaseRolesPrivileges(privileges []PrivilegeDto) []databaseRolesPrivilege {
+	optionalString := func(value string) types.String {
+		if value == "" {
+			return types.StringNull()
+		}
+		return types.StringValue(value)
+	}
+	flattened := make([]databaseRolesPrivilege, 0, len(privileges))
+	for _, privilege := range privileges {
+		actions := make([]types.String, 0, len(privilege.Actions))
+		for _, action := range privilege.Actions {
+			actions = append(actions, types.StringValue(action))
+		}
+		cluster := types.BoolNull()
+		if privilege.Cluster {
+			cluster = types.BoolValue(true)
+		}
+		flattened = append(flattened, databaseRolesPrivilege{
+			Db:            optionalString(privilege.Db),
+			Collection:    optionalString(privilege.Collection),
+			Cluster:       cluster,
+			SystemBuckets: optionalString(privilege.SystemBuckets),
+			Actions:       actions,
+		})
+	}
+	return flattened
+}
+
+/*
+the role names are checked during the plan like the ones of mongodb_db_role
+*/
+type roleNamesValidator struct{}
+
+func (v roleNamesValidator) Description(_ context.Context) string {
+	return "the keys must be valid role names"
+}
+
+func (v roleNamesValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v roleNamesValidator) ValidateMap(_ context.Context, req validator.MapRequest, resp *validator.MapResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	for name := range req.ConfigValue.Elements() {
+		if _, errors := validateRoleName(name, req.Path.String()); len(errors) != 0 {
+			resp.Diagnostics.AddAttributeError(req.Path, "Invalid role name", errors[0].Error())
+		}
+	}
+}
+
+/*
+the actions are checked during the plan like the ones of mongodb_db_role
+*/
+type privilegeActionsValidator struct{}
+
+func (v privilegeActionsValidator) Description(_ context.Context) string {
+	return "the actions must be privilege actions of mongodb"
+}
+
+func (v privilegeActionsValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v privilegeActionsValidator) ValidateList(ctx context.Context, req validator.ListRequest, resp *validator.ListResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	var actions []types.String
+	resp.Diagnostics.Append(req.ConfigValue.ElementsAs(ctx, &actions, false)...)
+	for _, action := range actions {
+		if action.IsUnknown() || action.IsNull() {
+			continue
+		}
+		warnings, errors := validatePrivilegeAction(action.ValueString(), req.Path.String())
+		if len(errors) != 0 {
+			resp.Diagnostics.AddAttributeError(req.Path, "Invalid privilege action", errors[0].Error())
+		}
+		for _, warning := range warnings {
+			resp.Diagnostics.AddAttributeWarning(req.Path, "Unknown privilege action", warning)
+		}
+	}
+}
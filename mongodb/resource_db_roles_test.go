@@ -0,0 +1,52 @@
+package mongodb
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func inheriting(roles ...string) databaseRolesRole {
+	var role databaseRolesRole
+	for _, inherited := range roles {
+		db, name, found := strings.Cut(inherited, ".")
+		if !found {
+			role.InheritedRoles = append(role.InheritedRoles, databaseUsersRole{Role: types.StringValue(inherited), Db: types.StringNull()})
+			continue
+		}
+		role.InheritedRoles = append(role.InheritedRoles, databaseUsersRole{Role: types.StringValue(name), Db: types.StringValue(db)})
+	}
+	return role
+}
+
+func TestRolesInCreationOrder(t *testing.T) {
+	roles := map[string]databaseRolesRole{
+		"a_tenant":  inheriting("b_base", "c_shared"),
+		"b_base":    inheriting("app.c_shared", "read"),
+		"c_shared":  inheriting(),
+		"d_other":   inheriting("other.a_tenant"),
+		"e_builtin": inheriting("admin.readAnyDatabase"),
+	}
+	order, err := rolesInCreationOrder(roles, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"c_shared", "b_base", "a_tenant", "d_other", "e_builtin"}
+	if !slices.Equal(order, expected) {
+		t.Fatalf("expected %v, got %v", expected, order)
+	}
+}
+
+func TestRolesInCreationOrderCycle(t *testing.T) {
+	roles := map[string]databaseRolesRole{
+		"a_tenant": inheriting("b_base"),
+		"b_base":   inheriting("app.c_shared"),
+		"c_shared": inheriting("a_tenant"),
+	}
+	_, err := rolesInCreationOrder(roles, "app")
+	if err == nil || !strings.Contains(err.Error(), "a_tenant -> b_base -> c_shared -> a_tenant") {
+		t.Fatalf("expected the cycle to be reported, got %v", err)
+	}
+}
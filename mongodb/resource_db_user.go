@@ -4,45 +4,159 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/mitchellh/mapstructure"
+	"regexp"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 	"strings"
+	"time"
 )
 
 func resourceDatabaseUser() *schema.Resource {
 	return &schema.Resource{
-		CreateContext: resourceDatabaseUserCreate,
-		ReadContext:   resourceDatabaseUserRead,
-		UpdateContext: resourceDatabaseUserUpdate,
-		DeleteContext: resourceDatabaseUserDelete,
+		CreateContext: tracedOperation("mongodb_db_user.create", resourceDatabaseUserCreate),
+		ReadContext:   tracedOperation("mongodb_db_user.read", resourceDatabaseUserRead),
+		UpdateContext: tracedOperation("mongodb_db_user.update", resourceDatabaseUserUpdate),
+		DeleteContext: tracedOperation("mongodb_db_user.delete", resourceDatabaseUserDelete),
 		Importer: &schema.ResourceImporter{
-			StateContext: schema.ImportStatePassthroughContext,
+			StateContext: resourceDatabaseUserImport,
+		},
+		Identity:      databaseNameIdentity("auth_database"),
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			resourceDatabaseUserStateUpgraderV0(),
+		},
+		CustomizeDiff: validateUserPasswordPolicy,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Read:   schema.DefaultTimeout(2 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
 		},
 		Schema: map[string]*schema.Schema{
 			"auth_database": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateDatabaseName,
 			},
 			"name":{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateUserName,
 			},
 			"password":{
-				Type:     schema.TypeString,
-				Required: true,
+				Type:             schema.TypeString,
+				Optional:         true,
+				Sensitive:        true,
+				ConflictsWith:    []string{"password_wo"},
+				DiffSuppressFunc: suppressIgnoredPasswordChange,
+			},
+			"password_wo": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				WriteOnly:     true,
+				ConflictsWith: []string{"password"},
+				Description:   "The password of the user, it is not stored in the state. Requires Terraform 1.11 or later",
+			},
+			"password_wo_version": {
+				Type:          schema.TypeInt,
+				Optional:      true,
+				ConflictsWith:    []string{"password"},
+				DiffSuppressFunc: suppressIgnoredPasswordChange,
+				Description:      "Change it to set password_wo again",
+			},
+			"verify_password": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Authenticate as the user during the refresh to detect a password changed outside of terraform",
+			},
+			"ignore_password_changes": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Only set the password when the user is created, e.g. when a secrets manager rotates it afterwards",
+			},
+			"on_conflict": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "fail",
+				ValidateFunc: validation.StringInSlice([]string{"fail", "adopt", "adopt_reset_password"}, false),
+				Description:  "What to do when the user already exists : fail, adopt it or adopt it and set its password",
+			},
+			"deletion_protection": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Refuse to drop the user while it is true",
+			},
+			"keepers": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Arbitrary values, a change sets the password again with updateUser",
+			},
+			"digest_password": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Set it to false when the password is already digested, the hex md5 of name:mongo:password",
+			},
+			"custom_data": {
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Metadata stored with the user, e.g. its owner or team",
+			},
+			"authentication_restriction": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "The addresses the user can authenticate from and to",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"client_source": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The ip addresses or cidr ranges the user can connect from",
+						},
+						"server_address": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "The ip addresses or cidr ranges of the server the user can connect to",
+						},
+					},
+				},
+			},
+			"mechanisms": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringInSlice([]string{"SCRAM-SHA-1", "SCRAM-SHA-256"}, false),
+				},
+				Description: "The SCRAM mechanisms of the credentials of the user, the server default is both",
 			},
 			"role": {
 				Type:     schema.TypeSet,
 				Optional: true,
 				MaxItems: 10,
+				Set:      roleHash,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"db": {
-							Type:     schema.TypeString,
-							Optional: true,
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validateDatabaseName,
+							Description:  "The database of the role, auth_database when it is not set, e.g. an application database for a user of admin",
 						},
 						"role": {
 							Type:     schema.TypeString,
@@ -58,31 +172,37 @@ func resourceDatabaseUser() *schema.Resource {
 
 
 func resourceDatabaseUserDelete(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
-	var client = i.(*mongo.Client)
+	var meta = i.(*ProviderMeta)
+	var client = meta.Client
 	var stateId = data.State().ID
 	var database = data.Get("auth_database").(string)
 
-	id, errEncoding := hex.DecodeString(stateId)
-	if errEncoding != nil {
-		return diag.Errorf("ID mismatch %s", errEncoding)
+	if data.Get("deletion_protection").(bool) {
+		return diag.Errorf("the user %s has deletion_protection, set it to false and apply before destroying or replacing the user", data.Get("name"))
 	}
 
 	// StateID is a concatination of database and username. We only use the username here.
-	splitId := strings.Split(string(id), ".")
-	userName := splitId[1]
+	// The username may hold dots, e.g. the LDAP DN uid=john.doe,ou=users,dc=example,dc=com
+	userName, _, errEncoding := resourceDatabaseUserParseId(stateId)
+	if errEncoding != nil {
+		return diag.Errorf("ID mismatch %s", errEncoding)
+	}
 
 	adminDB := client.Database(database)
 
-	result := adminDB.RunCommand(context.Background(), bson.D{{Key: "dropUser", Value: userName}})
-	if result.Err() != nil {
-		return diag.Errorf("%s",result.Err())
+	err := meta.retry(ctx, func() error {
+		return runCommand(ctx, adminDB, withWriteConcern(adminDB, bson.D{{Key: "dropUser", Value: userName}})).Err()
+	})
+	if err != nil {
+		return diag.Errorf("%s",err)
 	}
 
 	return resourceDatabaseUserRead(ctx, data, i)
 }
 
 func resourceDatabaseUserUpdate(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
-	var client = i.(*mongo.Client)
+	var meta = i.(*ProviderMeta)
+	var client = meta.Client
 
 	var stateId = data.State().ID
 	_, errEncoding := hex.DecodeString(stateId)
@@ -90,86 +210,200 @@ func resourceDatabaseUserUpdate(ctx context.Context, data *schema.ResourceData,
 		return diag.Errorf("ID mismatch %s", errEncoding)
 	}
 
+	/* name and auth_database force a new user, the user is updated in place, its sessions are kept */
 	var userName = data.Get("name").(string)
 	var database = data.Get("auth_database").(string)
-	var userPassword = data.Get("password").(string)
-	
-	adminDB := client.Database(database)
+	var userPassword = userPassword(data)
 
-	result := adminDB.RunCommand(context.Background(), bson.D{{Key: "dropUser", Value: userName}})
-	if result.Err() != nil {
-		return diag.Errorf("%s",result.Err())
+	var update bson.D
+	/*
+	a password removed from the configuration is kept, the password is needed
+	to add a mechanism
+	*/
+	var digested = !data.Get("digest_password").(bool)
+	var ignorePassword = data.Get("ignore_password_changes").(bool)
+	if data.HasChanges("password", "password_wo_version", "mechanisms", "digest_password", "keepers") && userPassword != "" && !ignorePassword {
+		if diags := validatePasswordDigest(data, userName, userPassword); diags != nil {
+			return diags
+		}
+		update = append(update, bson.E{Key: "pwd", Value: userPassword})
+		if digested {
+			update = append(update, bson.E{Key: "digestPassword", Value: false})
+		}
 	}
-	var roleList []Role
-	var user = DbUser{
-		Name:     userName,
-		Password: userPassword,
+	if data.HasChange("mechanisms") {
+		update = append(update, bson.E{Key: "mechanisms", Value: expandStringSet(data.Get("mechanisms"))})
+	} else if digested && data.HasChange("digest_password") {
+		update = append(update, bson.E{Key: "mechanisms", Value: []string{"SCRAM-SHA-1"}})
 	}
-	roles := data.Get("role").(*schema.Set).List()
-	roleMapErr := mapstructure.Decode(roles, &roleList)
-	if roleMapErr != nil {
-		return diag.Errorf("Error decoding map : %s ", roleMapErr)
+	if data.HasChange("authentication_restriction") {
+		update = append(update, bson.E{Key: "authenticationRestrictions", Value: expandAuthenticationRestrictions(data.Get("authentication_restriction").([]interface{}))})
 	}
-	err2 := createUser(client,user,roleList,database)
-	if err2 != nil {
-		return diag.Errorf("Could not create the user : %s ", err2)
+	if data.HasChange("custom_data") {
+		update = append(update, bson.E{Key: "customData", Value: data.Get("custom_data").(map[string]interface{})})
+	}
+	err := meta.retry(ctx, func() error {
+		return updateUser(ctx, client, userName, update, database)
+	})
+	if err != nil {
+		return diag.Errorf("Could not update the user : %s ", err)
+	}
+	/* a role without db is the role of auth_database, both sides are compared with the db set */
+	oldRoles, newRoles := data.GetChange("role")
+	var oldRoleList, newRoleList []Role
+	if err := mapstructure.Decode(oldRoles.(*schema.Set).List(), &oldRoleList); err != nil {
+		return diag.Errorf("Error decoding map : %s ", err)
+	}
+	if err := mapstructure.Decode(newRoles.(*schema.Set).List(), &newRoleList); err != nil {
+		return diag.Errorf("Error decoding map : %s ", err)
+	}
+	granted, revoked := roleDifference(defaultRoleDatabase(oldRoleList, database), defaultRoleDatabase(newRoleList, database))
+	err = meta.retry(ctx, func() error {
+		return updateUserRoles(ctx, client, "grantRolesToUser", userName, granted, database)
+	})
+	if err != nil {
+		return diag.Errorf("Could not grant the roles to the user : %s ", err)
+	}
+	err = meta.retry(ctx, func() error {
+		return updateUserRoles(ctx, client, "revokeRolesFromUser", userName, revoked, database)
+	})
+	if err != nil {
+		return diag.Errorf("Could not revoke the roles from the user : %s ", err)
 	}
-
-	newId := database+"."+userName
-	hexadecimal := hex.EncodeToString([]byte(newId))
-	data.SetId(hexadecimal)
 	return resourceDatabaseUserRead(ctx, data, i)
 }
 
 func resourceDatabaseUserRead(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
-	var client = i.(*mongo.Client)
+	var meta = i.(*ProviderMeta)
+	if meta.Client == nil {
+		// the provider configuration is unknown during this plan
+		return nil
+	}
+	var client = meta.Client
 	stateID := data.State().ID
 	username, database , err := resourceDatabaseUserParseId(stateID)
 	if err != nil {
 		return diag.Errorf("%s",err)
 	}
-	result , decodeError := getUser(client,username,database)
+	var result SingleResultGetUser
+	decodeError := meta.retry(ctx, func() error {
+		var err error
+		result, err = getUser(ctx, client,username,database, !meta.Config.DocDBCompatibility && !meta.Config.CosmosDBCompatibility)
+		return err
+	})
 	if decodeError != nil {
-		return diag.Errorf("Error decoding user : %s ", err)
+		return diag.Errorf("Error decoding user : %s ", decodeError)
 	}
 	if len(result.Users) == 0 {
-		return diag.Errorf("user does not exist")
+		/* dropped outside of terraform, it is planned for creation again */
+		tflog.Warn(ctx, "the user does not exist anymore, removing it from the state", map[string]interface{}{
+			"user":     username,
+			"database": database,
+		})
+		data.SetId("")
+		return nil
 	}
 	roles := make([]interface{}, len(result.Users[0].Roles))
+	configuredRoles := data.Get("role").(*schema.Set)
 
 	for i, s := range result.Users[0].Roles {
+			db := s.Db
+			/* a role configured without db is returned with the database of the user */
+			if db == database && configuredRoles.Contains(map[string]interface{}{"db": "", "role": s.Role}) {
+				db = ""
+			}
 			roles[i] = map[string]interface{}{
-				"db": s.Db,
+				"db": db,
 				"role": s.Role,
 			}
 	}
-	data.Set("role", roles)
+	customData := make(map[string]interface{}, len(result.Users[0].CustomData))
+	for key, value := range result.Users[0].CustomData {
+		customData[key] = fmt.Sprint(value)
+	}
+	/* the roles granted or revoked outside of terraform show up in the plan */
+	if err := data.Set("role", roles); err != nil {
+		return diag.Errorf("Error setting the roles of the user : %s ", err)
+	}
+	data.Set("name", username)
+	data.Set("custom_data", customData)
+	data.Set("mechanisms", result.Users[0].Mechanisms)
+	if !meta.Config.DocDBCompatibility && !meta.Config.CosmosDBCompatibility {
+		data.Set("authentication_restriction", flattenAuthenticationRestrictions(result.Users[0].AuthenticationRestrictions))
+	}
 	data.Set("auth_database", database)
 	data.Set("password", data.Get("password"))
 
 	data.SetId(stateID)
+	if err := setDatabaseNameIdentity(data, "auth_database", database, username); err != nil {
+		return diag.Errorf("Error setting the identity of the user : %s ", err)
+	}
 	diags = nil
+	/*
+	a password changed outside of terraform is removed from the state so the
+	plan sets it again, the write-only, digested and $external passwords can
+	not be verified, the ignored passwords are not
+	*/
+	if password := data.Get("password").(string); data.Get("verify_password").(bool) && !data.Get("ignore_password_changes").(bool) && password != "" && database != "$external" && data.Get("digest_password").(bool) {
+		valid, err := meta.Config.verifyCredentials(ctx, username, password, database)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Could not verify the password of the user",
+				Detail:   fmt.Sprintf("%s : %s", username, err),
+			})
+		} else if !valid {
+			tflog.Warn(ctx, "the password of the user was changed outside of terraform", map[string]interface{}{
+				"user":     username,
+				"database": database,
+			})
+			data.Set("password", "")
+		}
+	}
 	return diags
 }
 
 func resourceDatabaseUserCreate(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
 
-	var client = i.(*mongo.Client)
+	var meta = i.(*ProviderMeta)
+	var client = meta.Client
 	var database = data.Get("auth_database").(string)
 	var userName = data.Get("name").(string)
-	var userPassword = data.Get("password").(string)
+	var userPassword = userPassword(data)
+	if diags := validateUserPassword(database, userPassword); diags != nil {
+		return diags
+	}
+	if diags := validateExternalUserName(database, userName); diags != nil {
+		return diags
+	}
+	if diags := validatePasswordDigest(data, userName, userPassword); diags != nil {
+		return diags
+	}
 	var roleList []Role
 	var user = DbUser{
 		Name:     userName,
 		Password: userPassword,
+		CustomData: data.Get("custom_data").(map[string]interface{}),
+		AuthenticationRestrictions: expandAuthenticationRestrictions(data.Get("authentication_restriction").([]interface{})),
+		Mechanisms: expandStringSet(data.Get("mechanisms")),
+		PasswordDigested: !data.Get("digest_password").(bool),
 	}
 	roles := data.Get("role").(*schema.Set).List()
 	roleMapErr := mapstructure.Decode(roles, &roleList)
 	if roleMapErr != nil {
 		return diag.Errorf("Error decoding map : %s ", roleMapErr)
 	}
-	err := createUser(client,user,roleList,database)
+	err := meta.retry(ctx, func() error {
+		return createUser(ctx, client,user,roleList,database)
+	})
+	if err != nil && isUserAlreadyExists(err) && data.Get("on_conflict").(string) != "fail" {
+		tflog.Info(ctx, "the user already exists, adopting it", map[string]interface{}{
+			"user":     userName,
+			"database": database,
+		})
+		err = adoptUser(ctx, meta, user, roleList, database, data.Get("on_conflict").(string) == "adopt_reset_password")
+	}
 	if err != nil {
 		return diag.Errorf("Could not create the user : %s ", err)
 	}
@@ -179,6 +413,257 @@ func resourceDatabaseUserCreate(ctx context.Context, data *schema.ResourceData,
 	return resourceDatabaseUserRead(ctx, data, i)
 }
 
+/*
+an existing user is updated to the configuration, its password is kept
+unless it is reset
+*/
+func adoptUser(ctx context.Context, meta *ProviderMeta, user DbUser, roles []Role, database string, resetPassword bool) error {
+	var result SingleResultGetUser
+	err := meta.retry(ctx, func() error {
+		var err error
+		result, err = getUser(ctx, meta.Client, user.Name, database, false)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(result.Users) == 0 {
+		return fmt.Errorf("the user %s could not be read", user.Name)
+	}
+	var existing, configured []Role
+	for _, role := range result.Users[0].Roles {
+		existing = append(existing, Role{Role: role.Role, Db: role.Db})
+	}
+	for _, role := range roles {
+		if role.Db == "" {
+			role.Db = database
+		}
+		configured = append(configured, role)
+	}
+	granted, revoked := roleDifference(existing, configured)
+
+	var customData = bson.M{}
+	for key, value := range user.CustomData {
+		customData[key] = value
+	}
+	update := bson.D{{Key: "customData", Value: customData}}
+	if resetPassword && user.Password != "" {
+		update = append(update, bson.E{Key: "pwd", Value: user.Password})
+		if user.PasswordDigested {
+			update = append(update, bson.E{Key: "digestPassword", Value: false})
+		}
+		if len(user.Mechanisms) != 0 {
+			update = append(update, bson.E{Key: "mechanisms", Value: user.Mechanisms})
+		}
+	}
+	if !meta.Config.DocDBCompatibility && !meta.Config.CosmosDBCompatibility {
+		update = append(update, bson.E{Key: "authenticationRestrictions", Value: user.AuthenticationRestrictions})
+	}
+	return meta.retry(ctx, func() error {
+		if err := updateUser(ctx, meta.Client, user.Name, update, database); err != nil {
+			return err
+		}
+		if err := updateUserRoles(ctx, meta.Client, "grantRolesToUser", user.Name, granted, database); err != nil {
+			return err
+		}
+		return updateUserRoles(ctx, meta.Client, "revokeRolesFromUser", user.Name, revoked, database)
+	})
+}
+
+/*
+the users of $external authenticate with their x.509 certificate, with
+ldap or with MONGODB-AWS, their name is the subject of the certificate, the
+ldap dn or the arn of the iam user or role
+*/
+func validateUserPassword(database string, password string) diag.Diagnostics {
+	if database == "$external" {
+		if password != "" {
+			return diag.Errorf("the users of $external authenticate with a x.509 certificate, LDAP or MONGODB-AWS, password and password_wo can not be set")
+		}
+		return nil
+	}
+	if password == "" {
+		return diag.Errorf("one of password or password_wo is required")
+	}
+	return nil
+}
+
+/*
+MONGODB-AWS matches the arn of the iam user, or the arn of the role for the
+assumed roles, a session arn of sts never matches
+*/
+var iamArnPattern = regexp.MustCompile(`^arn:aws(-[a-z]+)*:iam::[0-9]{12}:(user|role)/.+$`)
+
+func validateExternalUserName(database string, name string) diag.Diagnostics {
+	if database != "$external" || !strings.HasPrefix(name, "arn:") {
+		return nil
+	}
+	if strings.HasPrefix(name, "arn:aws:sts:") || strings.Contains(name, ":assumed-role/") {
+		return diag.Errorf("the user %s is a session of sts, MONGODB-AWS authenticates the assumed roles with the arn of the role, e.g. arn:aws:iam::123456789012:role/name", name)
+	}
+	if !iamArnPattern.MatchString(name) {
+		return diag.Errorf("the user %s is not the arn of an iam user or role, e.g. arn:aws:iam::123456789012:role/name", name)
+	}
+	return nil
+}
+
+/*
+the password of an existing user is managed outside of terraform, the
+changes of the configuration are not planned
+*/
+func suppressIgnoredPasswordChange(k, old, new string, d *schema.ResourceData) bool {
+	return d.Id() != "" && d.Get("ignore_password_changes").(bool)
+}
+
+var passwordDigestPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+/*
+with digest_password = false the password is digested by the client, the
+server can only derive SCRAM-SHA-1 credentials from it
+*/
+func validatePasswordDigest(data *schema.ResourceData, userName string, password string) diag.Diagnostics {
+	if data.Get("digest_password").(bool) || password == "" {
+		return nil
+	}
+	if !passwordDigestPattern.MatchString(password) {
+		return diag.Errorf("with digest_password = false the password must be the hex encoded md5 of %s:mongo:<password>", userName)
+	}
+	if !setInConfig(data.GetRawConfig(), "mechanisms") {
+		/* the computed mechanisms are replaced by SCRAM-SHA-1 */
+		return nil
+	}
+	for _, mechanism := range expandStringSet(data.Get("mechanisms")) {
+		if mechanism != "SCRAM-SHA-1" {
+			return diag.Errorf("with digest_password = false the mechanisms can only be SCRAM-SHA-1, %s needs the password", mechanism)
+		}
+	}
+	return nil
+}
+
+/*
+the user names are checked before the apply, the server would refuse them
+or they would not be usable, the names of $external can hold dots, commas
+and equal signs
+*/
+func validateUserName(v interface{}, k string) (warnings []string, errors []error) {
+	name := v.(string)
+	switch {
+	case name == "":
+		errors = append(errors, fmt.Errorf("expected %s to not be empty", k))
+	case len(name) > 1024:
+		errors = append(errors, fmt.Errorf("expected %s to be at most 1024 bytes, got %d", k, len(name)))
+	case strings.ContainsRune(name, 0):
+		errors = append(errors, fmt.Errorf("expected %s to not contain a null byte", k))
+	case strings.HasPrefix(name, "$"):
+		errors = append(errors, fmt.Errorf("expected %s to not start with $, got %q", k, name))
+	case strings.TrimSpace(name) != name:
+		errors = append(errors, fmt.Errorf("expected %s to not start or end with whitespace, got %q", k, name))
+	case name == "__system" || name == "__queryable_backup":
+		errors = append(errors, fmt.Errorf("%s is reserved for the internal users of mongodb", name))
+	}
+	return warnings, errors
+}
+
+/*
+the characters mongodb refuses in database names, $external is the
+database of the users authenticated outside of mongodb
+*/
+func validateDatabaseName(v interface{}, k string) (warnings []string, errors []error) {
+	name := v.(string)
+	switch {
+	case name == "$external":
+	case name == "":
+		errors = append(errors, fmt.Errorf("expected %s to not be empty, leave it out to use the database of the user", k))
+	case len(name) >= 64:
+		errors = append(errors, fmt.Errorf("expected %s to be shorter than 64 bytes, got %d", k, len(name)))
+	case strings.ContainsAny(name, "/\\. \"$*<>:|?\x00"):
+		errors = append(errors, fmt.Errorf("expected %s to not contain any of /\\. \"$*<>:|? or a null byte, got %q", k, name))
+	}
+	return warnings, errors
+}
+
+/*
+the roles are compared by name and database, whatever their order in the
+configuration or in usersInfo
+*/
+func roleHash(v interface{}) int {
+	role := v.(map[string]interface{})
+	return schema.HashString(fmt.Sprintf("%s.%s", role["db"], role["role"]))
+}
+
+func expandStringSet(value interface{}) []string {
+	var values []string
+	for _, element := range value.(*schema.Set).List() {
+		values = append(values, element.(string))
+	}
+	return values
+}
+
+func expandAuthenticationRestrictions(restrictionList []interface{}) []AuthenticationRestriction {
+	restrictions := make([]AuthenticationRestriction, 0, len(restrictionList))
+	for _, element := range restrictionList {
+		restriction := AuthenticationRestriction{}
+		if block, ok := element.(map[string]interface{}); ok {
+			for _, address := range block["client_source"].([]interface{}) {
+				restriction.ClientSource = append(restriction.ClientSource, address.(string))
+			}
+			for _, address := range block["server_address"].([]interface{}) {
+				restriction.ServerAddress = append(restriction.ServerAddress, address.(string))
+			}
+		}
+		restrictions = append(restrictions, restriction)
+	}
+	return restrictions
+}
+
+func flattenAuthenticationRestrictions(restrictions []AuthenticationRestriction) []interface{} {
+	restrictionList := make([]interface{}, 0, len(restrictions))
+	for _, restriction := range restrictions {
+		restrictionList = append(restrictionList, map[string]interface{}{
+			"client_source":  restriction.ClientSource,
+			"server_address": restriction.ServerAddress,
+		})
+	}
+	return restrictionList
+}
+
+/*
+the write-only password is only in the configuration, it never lands in
+the state
+*/
+func userPassword(data *schema.ResourceData) string {
+	rawConfig := data.GetRawConfig()
+	if setInConfig(rawConfig, "password_wo") {
+		if value := rawConfig.GetAttr("password_wo"); value.IsKnown() {
+			return value.AsString()
+		}
+	}
+	return data.Get("password").(string)
+}
+
+/*
+the import id is the hex encoded id of the state or database.user, a hex
+encoded id never holds a dot
+*/
+func resourceDatabaseUserImport(ctx context.Context, data *schema.ResourceData, i interface{}) ([]*schema.ResourceData, error) {
+	if err := setIdFromIdentity(data, "auth_database"); err != nil {
+		return nil, err
+	}
+	if strings.Contains(data.Id(), ".") {
+		data.SetId(hex.EncodeToString([]byte(data.Id())))
+	}
+	if _, _, err := resourceDatabaseUserParseId(data.Id()); err != nil {
+		return nil, fmt.Errorf("unexpected format of ID (%s), expected database.user", data.Id())
+	}
+	/* the defaults are not read from the server */
+	data.Set("deletion_protection", false)
+	data.Set("digest_password", true)
+	data.Set("on_conflict", "fail")
+	data.Set("verify_password", false)
+	data.Set("ignore_password_changes", false)
+	return []*schema.ResourceData{data}, nil
+}
+
 func resourceDatabaseUserParseId(id string) (string, string, error){
 	result , errEncoding := hex.DecodeString(id)
 
@@ -0,0 +1,339 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"sort"
+)
+
+/*
+the users of a database managed as one resource, the refresh reads the
+managed users by batches instead of one usersInfo per user
+*/
+type databaseUsersResource struct {
+	meta *ProviderMeta
+}
+
+type databaseUsersModel struct {
+	Id           types.String                 `tfsdk:"id"`
+	AuthDatabase types.String                 `tfsdk:"auth_database"`
+	Users        map[string]databaseUsersUser `tfsdk:"users"`
+}
+
+type databaseUsersUser struct {
+	Password types.String        `tfsdk:"password"`
+	Roles    []databaseUsersRole `tfsdk:"roles"`
+}
+
+type databaseUsersRole struct {
+	Role types.String `tfsdk:"role"`
+	Db   types.String `tfsdk:"db"`
+}
+
+func NewDatabaseUsersResource() resource.Resource {
+	return &databaseUsersResource{}
+}
+
+func (r *databaseUsersResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_db_users"
+}
+
+func (r *databaseUsersResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "The users of a database, keyed by user name",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"auth_database": schema.StringAttribute{
+				Required:      true,
+				Description:   "The database the users are created in",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"users": schema.MapNestedAttribute{
+				Required:    true,
+				Description: "The users keyed by user name",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"password": schema.StringAttribute{
+							Required:    true,
+							Sensitive:   true,
+							Description: "The password of the user",
+						},
+						"roles": schema.SetNestedAttribute{
+							Optional:    true,
+							Description: "The roles granted to the user",
+							NestedObject: schema.NestedAttributeObject{
+								Attributes: map[string]schema.Attribute{
+									"role": schema.StringAttribute{
+										Required:    true,
+										Description: "The role name",
+									},
+									"db": schema.StringAttribute{
+										Optional:    true,
+										Description: "The database of the role, auth_database when it is not set",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *databaseUsersResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	if req.ProviderData == nil {
+		return
+	}
+	meta, ok := req.ProviderData.(*ProviderMeta)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected provider data", fmt.Sprintf("expected *ProviderMeta, got %T", req.ProviderData))
+		return
+	}
+	r.meta = meta
+}
+
+func (r *databaseUsersResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := tracer.Start(ctx, "mongodb_db_users.create")
+	defer span.End()
+	var plan databaseUsersModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	database := plan.AuthDatabase.ValueString()
+	created := databaseUsersModel{
+		Id:           types.StringValue(database),
+		AuthDatabase: plan.AuthDatabase,
+		Users:        map[string]databaseUsersUser{},
+	}
+	for _, name := range sortedNames(plan.Users) {
+		user := plan.Users[name]
+		err := r.meta.retry(ctx, func() error {
+			return createUser(ctx, r.meta.Client, DbUser{Name: name, Password: user.Password.ValueString()}, user.roleList(database), database)
+		})
+		if err != nil {
+			/* the users created so far are kept in the state */
+			resp.Diagnostics.AddError("Could not create the user", fmt.Sprintf("%s : %s", name, scrubSecrets(err.Error(), user.Password.ValueString())))
+			resp.Diagnostics.Append(resp.State.Set(ctx, &created)...)
+			return
+		}
+		created.Users[name] = user
+	}
+	resp.Diagnostics.Append(resp.State.Set(ctx, &created)...)
+}
+
+func (r *databaseUsersResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := tracer.Start(ctx, "mongodb_db_users.read")
+	defer span.End()
+	if r.meta == nil || r.meta.Client == nil {
+		// the provider configuration is unknown during this plan
+		return
+	}
+	var state databaseUsersModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	database := state.AuthDatabase.ValueString()
+	var result SingleResultGetUser
+	err := r.meta.retry(ctx, func() error {
+		var err error
+		result, err = getUsers(ctx, r.meta.Client, sortedNames(state.Users), database)
+		return err
+	})
+	if err != nil {
+		resp.Diagnostics.AddError("Could not read the users", fmt.Sprintf("%s : %s", database, scrubSecrets(err.Error())))
+		return
+	}
+	existing := map[string]int{}
+	for index, user := range result.Users {
+		existing[user.User] = index
+	}
+	users := map[string]databaseUsersUser{}
+	for name, user := range state.Users {
+		index, ok := existing[name]
+		if !ok {
+			/* dropped outside of terraform, it is planned for creation again */
+			continue
+		}
+		roles := make([]databaseUsersRole, 0, len(result.Users[index].Roles))
+		for _, role := range result.Users[index].Roles {
+			db := types.StringValue(role.Db)
+			/* a role configured without db is returned with the database of the user */
+			if role.Db == database && user.hasRoleWithoutDb(role.Role) {
+				db = types.StringNull()
+			}
+			roles = append(roles, databaseUsersRole{Role: types.StringValue(role.Role), Db: db})
+		}
+		if len(roles) == 0 && user.Roles == nil {
+			roles = nil
+		}
+		users[name] = databaseUsersUser{Password: user.Password, Roles: roles}
+	}
+	state.Users = users
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *databaseUsersResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := tracer.Start(ctx, "mongodb_db_users.update")
+	defer span.End()
+	var plan, state databaseUsersModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	database := plan.AuthDatabase.ValueString()
+	/* the state follows the users which were reconciled */
+	current := make(map[string]databaseUsersUser, len(state.Users))
+	for name, user := range state.Users {
+		current[name] = user
+	}
+	save := func() {
+		state.Users = current
+		resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+	}
+	for _, name := range sortedNames(state.Users) {
+		if _, ok := plan.Users[name]; ok {
+			continue
+		}
+		err := r.meta.retry(ctx, func() error {
+			return dropUser(ctx, r.meta.Client, name, database)
+		})
+		if err != nil && !isUserNotFound(err) {
+			resp.Diagnostics.AddError("Could not drop the user", fmt.Sprintf("%s : %s", name, scrubSecrets(err.Error())))
+			save()
+			return
+		}
+		delete(current, name)
+	}
+	for _, name := range sortedNames(plan.Users) {
+		user := plan.Users[name]
+		password := user.Password.ValueString()
+		previous, exists := state.Users[name]
+		var err error
+		if !exists {
+			err = r.meta.retry(ctx, func() error {
+				return createUser(ctx, r.meta.Client, DbUser{Name: name, Password: password}, user.roleList(database), database)
+			})
+		} else {
+			granted, revoked := roleDifference(previous.roleList(database), user.roleList(database))
+			if previous.Password.ValueString() != password {
+				err = r.meta.retry(ctx, func() error {
+					return updateUser(ctx, r.meta.Client, name, bson.D{{Key: "pwd", Value: password}}, database)
+				})
+			}
+			if err == nil {
+				err = r.meta.retry(ctx, func() error {
+					return updateUserRoles(ctx, r.meta.Client, "grantRolesToUser", name, granted, database)
+				})
+			}
+			if err == nil {
+				err = r.meta.retry(ctx, func() error {
+					return updateUserRoles(ctx, r.meta.Client, "revokeRolesFromUser", name, revoked, database)
+				})
+			}
+		}
+		if err != nil {
+			resp.Diagnostics.AddError("Could not update the user", fmt.Sprintf("%s : %s", name, scrubSecrets(err.Error(), password)))
+			save()
+			return
+		}
+		current[name] = user
+	}
+	state.AuthDatabase = plan.AuthDatabase
+	save()
+}
+
+func (r *databaseUsersResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := tracer.Start(ctx, "mongodb_db_users.delete")
+	defer span.End()
+	var state databaseUsersModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	database := state.AuthDatabase.ValueString()
+	for _, name := range sortedNames(state.Users) {
+		err := r.meta.retry(ctx, func() error {
+			return dropUser(ctx, r.meta.Client, name, database)
+		})
+		if err != nil && !isUserNotFound(err) {
+			resp.Diagnostics.AddError("Could not drop the user", fmt.Sprintf("%s : %s", name, scrubSecrets(err.Error())))
+			return
+		}
+	}
+}
+
+/*
+a role without db is the role of auth_database
+*/
+func (u databaseUsersUser) roleList(database string) []Role {
+	roles := make([]Role, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		db := role.Db.ValueString()
+		if db == "" {
+			db = database
+		}
+		roles = append(roles, Role{Role: role.Role.ValueString(), Db: db})
+	}
+	return roles
+}
+
+func (u databaseUsersUser) hasRoleWithoutDb(name string) bool {
+	for _, role := range u.Roles {
+		if role.Role.ValueString() == name && role.Db.ValueString() == "" {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+the roles to grant and to revoke to go from one list to the other
+*/
+func roleDifference(from []Role, to []Role) ([]Role, []Role) {
+	var granted, revoked []Role
+	for _, role := range to {
+		if !containsRole(from, role) {
+			granted = append(granted, role)
+		}
+	}
+	for _, role := range from {
+		if !containsRole(to, role) {
+			revoked = append(revoked, role)
+		}
+	}
+	return granted, revoked
+}
+
+func containsRole(roles []Role, role Role) bool {
+	for _, element := range roles {
+		if element == role {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+the users and the roles are created in a stable order
+*/
+func sortedNames[T any](elements map[string]T) []string {
+	names := make([]string, 0, len(elements))
+	for name := range elements {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
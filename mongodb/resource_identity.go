@@ -0,0 +1,62 @@
+package mongodb
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+/*
+the identity of the users and the roles is their database and their name,
+the import blocks can use it instead of the hex encoded id
+*/
+func databaseNameIdentity(databaseAttribute string) *schema.ResourceIdentity {
+	return &schema.ResourceIdentity{
+		Version: 0,
+		SchemaFunc: func() map[string]*schema.Schema {
+			return map[string]*schema.Schema{
+				databaseAttribute: {
+					Type:              schema.TypeString,
+					RequiredForImport: true,
+					Description:       "The database",
+				},
+				"name": {
+					Type:              schema.TypeString,
+					RequiredForImport: true,
+					Description:       "The name",
+				},
+			}
+		},
+	}
+}
+
+func setDatabaseNameIdentity(data *schema.ResourceData, databaseAttribute string, database string, name string) error {
+	identity, err := data.Identity()
+	if err != nil {
+		return err
+	}
+	if err := identity.Set(databaseAttribute, database); err != nil {
+		return err
+	}
+	return identity.Set("name", name)
+}
+
+/*
+an import by identity has no id, it is built from the identity
+*/
+func setIdFromIdentity(data *schema.ResourceData, databaseAttribute string) error {
+	if data.Id() != "" {
+		return nil
+	}
+	identity, err := data.Identity()
+	if err != nil {
+		return err
+	}
+	database, _ := identity.Get(databaseAttribute).(string)
+	name, _ := identity.Get("name").(string)
+	if database == "" || name == "" {
+		return fmt.Errorf("the identity requires %s and name", databaseAttribute)
+	}
+	data.SetId(hex.EncodeToString([]byte(database + "." + name)))
+	return nil
+}
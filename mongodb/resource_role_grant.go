@@ -0,0 +1,150 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"time"
+)
+
+/*
+a single role granted to a role managed elsewhere, the id is the hex
+encoded database.role and db.granted_role joined by a slash, like the
+user role bindings
+*/
+func resourceRoleGrant() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: tracedOperation("mongodb_role_grant.create", resourceRoleGrantCreate),
+		ReadContext:   tracedOperation("mongodb_role_grant.read", resourceRoleGrantRead),
+		DeleteContext: tracedOperation("mongodb_role_grant.delete", resourceRoleGrantDelete),
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceRoleGrantImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Read:   schema.DefaultTimeout(2 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"database": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "admin",
+				ForceNew:    true,
+				Description: "The database of the role",
+			},
+			"role": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateRoleName,
+				Description:  "The role the granted role is granted to",
+			},
+			"granted_role": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The role inherited by role",
+			},
+			"granted_role_db": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "The database of the granted role, the database of the role when it is not set",
+			},
+		},
+	}
+}
+
+func resourceRoleGrantCreate(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
+	var meta = i.(*ProviderMeta)
+	if diags := cosmosDBUnsupported(meta, "mongodb_role_grant"); diags != nil {
+		return diags
+	}
+	var database = data.Get("database").(string)
+	var roleName = data.Get("role").(string)
+	var granted = Role{Role: data.Get("granted_role").(string), Db: data.Get("granted_role_db").(string)}
+	if granted.Db == "" {
+		granted.Db = database
+	}
+	err := meta.retry(ctx, func() error {
+		return updateRoleRoles(ctx, meta.Client, "grantRolesToRole", roleName, []Role{granted}, database)
+	})
+	if err != nil {
+		return diag.Errorf("Could not grant the role %s to the role %s : %s ", granted.Role, roleName, err)
+	}
+	data.SetId(userRoleBindingId(database, roleName, granted))
+	return resourceRoleGrantRead(ctx, data, i)
+}
+
+func resourceRoleGrantRead(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
+	var meta = i.(*ProviderMeta)
+	if meta.Client == nil {
+		// the provider configuration is unknown during this plan
+		return nil
+	}
+	if diags := cosmosDBUnsupported(meta, "mongodb_role_grant"); diags != nil {
+		return diags
+	}
+	database, roleName, granted, err := parseUserRoleBindingId(data.Id())
+	if err != nil {
+		return diag.Errorf("%s", err)
+	}
+	var result SingleResultGetRole
+	err = meta.retry(ctx, func() error {
+		var err error
+		result, err = getRole(ctx, meta.Client, roleName, database)
+		return err
+	})
+	if err != nil {
+		return diag.Errorf("Error decoding role : %s ", err)
+	}
+	found := false
+	if len(result.Roles) != 0 {
+		/* only the direct roles, inheritedRoles holds the roles of the roles too */
+		for _, role := range result.Roles[0].Roles {
+			if role == granted {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		/* the role was revoked or dropped outside of terraform */
+		tflog.Warn(ctx, "the role is not granted to the role anymore, removing it from the state", map[string]interface{}{
+			"role":         roleName,
+			"granted_role": granted.String(),
+		})
+		data.SetId("")
+		return nil
+	}
+	data.Set("database", database)
+	data.Set("role", roleName)
+	data.Set("granted_role", granted.Role)
+	data.Set("granted_role_db", granted.Db)
+	return nil
+}
+
+func resourceRoleGrantDelete(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
+	var meta = i.(*ProviderMeta)
+	database, roleName, granted, err := parseUserRoleBindingId(data.Id())
+	if err != nil {
+		return diag.Errorf("%s", err)
+	}
+	err = meta.retry(ctx, func() error {
+		return updateRoleRoles(ctx, meta.Client, "revokeRolesFromRole", roleName, []Role{granted}, database)
+	})
+	if err != nil && !isRoleNotFound(err) {
+		return diag.Errorf("Could not revoke the role %s from the role %s : %s ", granted.Role, roleName, err)
+	}
+	return nil
+}
+
+/*
+the import id is the one of the state, or database.role/db.inheritedRole
+*/
+func resourceRoleGrantImport(ctx context.Context, data *schema.ResourceData, i interface{}) ([]*schema.ResourceData, error) {
+	return importRoleBinding(data, "database.role/db.inheritedRole")
+}
@@ -0,0 +1,133 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+/*
+the version 0 states of the users and the roles were written by the first
+releases, the upgrade normalizes their id to the hex encoded database.name
+and sets the attributes added since then to their default, so the existing
+states neither need a new import nor show a diff
+*/
+
+func resourceDatabaseUserStateUpgraderV0() schema.StateUpgrader {
+	return schema.StateUpgrader{
+		Version: 0,
+		Type: impliedType(map[string]*schema.Schema{
+			"auth_database": {Type: schema.TypeString, Required: true},
+			"name":          {Type: schema.TypeString, Required: true},
+			"password":      {Type: schema.TypeString, Required: true},
+			"role":          inheritedRoleSchemaV0(),
+		}),
+		Upgrade: resourceDatabaseUserStateUpgradeV0,
+	}
+}
+
+func resourceDatabaseUserStateUpgradeV0(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	if rawState == nil {
+		return rawState, nil
+	}
+	upgradeDatabaseNameId(rawState, "auth_database")
+	setMissingDefaults(rawState, map[string]interface{}{
+		"verify_password":         false,
+		"ignore_password_changes": false,
+		"on_conflict":             "fail",
+		"deletion_protection":     false,
+		"digest_password":         true,
+	})
+	return rawState, nil
+}
+
+func resourceDatabaseRoleStateUpgraderV0() schema.StateUpgrader {
+	return schema.StateUpgrader{
+		Version: 0,
+		Type: impliedType(map[string]*schema.Schema{
+			"database": {Type: schema.TypeString, Optional: true, Default: "admin"},
+			"name":     {Type: schema.TypeString, Required: true},
+			"privilege": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"db":         {Type: schema.TypeString, Optional: true},
+						"collection": {Type: schema.TypeString, Optional: true},
+						"actions": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+			"inherited_role": inheritedRoleSchemaV0(),
+		}),
+		Upgrade: resourceDatabaseRoleStateUpgradeV0,
+	}
+}
+
+func resourceDatabaseRoleStateUpgradeV0(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	if rawState == nil {
+		return rawState, nil
+	}
+	upgradeDatabaseNameId(rawState, "database")
+	if privileges, ok := rawState["privilege"].([]interface{}); ok {
+		for _, privilege := range privileges {
+			if privilege, ok := privilege.(map[string]interface{}); ok {
+				setMissingDefaults(privilege, map[string]interface{}{"cluster": false})
+			}
+		}
+	}
+	return rawState, nil
+}
+
+func inheritedRoleSchemaV0() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeSet,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"db":   {Type: schema.TypeString, Optional: true},
+				"role": {Type: schema.TypeString, Required: true},
+			},
+		},
+	}
+}
+
+func impliedType(attributes map[string]*schema.Schema) cty.Type {
+	return (&schema.Resource{Schema: attributes}).CoreConfigSchema().ImpliedType()
+}
+
+/*
+the ids written by hand before the import accepted database.name are plain
+text, the ones encoded with echo hold a trailing newline
+*/
+func upgradeDatabaseNameId(rawState map[string]interface{}, databaseAttribute string) {
+	id, _ := rawState["id"].(string)
+	if decoded, err := hex.DecodeString(id); err == nil && id != "" {
+		id = string(decoded)
+	}
+	id = strings.TrimRight(id, "\r\n")
+	if !strings.Contains(id, ".") {
+		database, _ := rawState[databaseAttribute].(string)
+		name, _ := rawState["name"].(string)
+		if database == "" || name == "" {
+			return
+		}
+		id = database + "." + name
+	}
+	rawState["id"] = hex.EncodeToString([]byte(id))
+}
+
+func setMissingDefaults(rawState map[string]interface{}, defaults map[string]interface{}) {
+	for key, value := range defaults {
+		if rawState[key] == nil {
+			rawState[key] = value
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"strings"
+	"time"
+)
+
+/*
+a single role granted to a user managed elsewhere, the id is the hex
+encoded database.user and db.role joined by a slash
+*/
+func resourceUserRoleBinding() *schema.Resource {
+	return &schema.Resource{
+		CreateContext: tracedOperation("mongodb_user_role_binding.create", resourceUserRoleBindingCreate),
+		ReadContext:   tracedOperation("mongodb_user_role_binding.read", resourceUserRoleBindingRead),
+		DeleteContext: tracedOperation("mongodb_user_role_binding.delete", resourceUserRoleBindingDelete),
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceUserRoleBindingImport,
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Read:   schema.DefaultTimeout(2 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"auth_database": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The database of the user",
+			},
+			"user": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateUserName,
+				Description:  "The name of the user",
+			},
+			"role": {
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "The role granted to the user",
+			},
+			"db": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+				Description: "The database of the role, the database of the user when it is not set",
+			},
+		},
+	}
+}
+
+func resourceUserRoleBindingCreate(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
+	var meta = i.(*ProviderMeta)
+	var database = data.Get("auth_database").(string)
+	var userName = data.Get("user").(string)
+	var role = Role{Role: data.Get("role").(string), Db: data.Get("db").(string)}
+	if role.Db == "" {
+		role.Db = database
+	}
+	err := meta.retry(ctx, func() error {
+		return updateUserRoles(ctx, meta.Client, "grantRolesToUser", userName, []Role{role}, database)
+	})
+	if err != nil {
+		return diag.Errorf("Could not grant the role %s to the user %s : %s ", role.Role, userName, err)
+	}
+	data.SetId(userRoleBindingId(database, userName, role))
+	return resourceUserRoleBindingRead(ctx, data, i)
+}
+
+func resourceUserRoleBindingRead(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
+	var meta = i.(*ProviderMeta)
+	if meta.Client == nil {
+		// the provider configuration is unknown during this plan
+		return nil
+	}
+	database, userName, role, err := parseUserRoleBindingId(data.Id())
+	if err != nil {
+		return diag.Errorf("%s", err)
+	}
+	var result SingleResultGetUser
+	err = meta.retry(ctx, func() error {
+		var err error
+		result, err = getUser(ctx, meta.Client, userName, database, false)
+		return err
+	})
+	if err != nil {
+		return diag.Errorf("Error decoding user : %s ", err)
+	}
+	granted := false
+	if len(result.Users) != 0 {
+		for _, userRole := range result.Users[0].Roles {
+			if userRole.Role == role.Role && userRole.Db == role.Db {
+				granted = true
+				break
+			}
+		}
+	}
+	if !granted {
+		/* the role was revoked or the user dropped outside of terraform */
+		tflog.Warn(ctx, "the role is not granted to the user anymore, removing it from the state", map[string]interface{}{
+			"user": userName,
+			"role": role.String(),
+		})
+		data.SetId("")
+		return nil
+	}
+	data.Set("auth_database", database)
+	data.Set("user", userName)
+	data.Set("role", role.Role)
+	data.Set("db", role.Db)
+	return nil
+}
+
+func resourceUserRoleBindingDelete(ctx context.Context, data *schema.ResourceData, i interface{}) diag.Diagnostics {
+	var meta = i.(*ProviderMeta)
+	database, userName, role, err := parseUserRoleBindingId(data.Id())
+	if err != nil {
+		return diag.Errorf("%s", err)
+	}
+	err = meta.retry(ctx, func() error {
+		return updateUserRoles(ctx, meta.Client, "revokeRolesFromUser", userName, []Role{role}, database)
+	})
+	/* the user or the role dropped outside of terraform is already gone */
+	if err != nil && !isUserNotFound(err) && !isRoleNotFound(err) {
+		return diag.Errorf("Could not revoke the role %s from the user %s : %s ", role.Role, userName, err)
+	}
+	return nil
+}
+
+/*
+the import id is the one of the state, or database.user/db.role, it is cut
+on the last slash as the arns of the iam users hold slashes
+*/
+func resourceUserRoleBindingImport(ctx context.Context, data *schema.ResourceData, i interface{}) ([]*schema.ResourceData, error) {
+	return importRoleBinding(data, "database.user/db.role")
+}
+
+func importRoleBinding(data *schema.ResourceData, format string) ([]*schema.ResourceData, error) {
+	if _, _, _, err := parseUserRoleBindingId(data.Id()); err == nil {
+		return []*schema.ResourceData{data}, nil
+	}
+	separator := strings.LastIndex(data.Id(), "/")
+	userParts := strings.SplitN(data.Id()[:max(separator, 0)], ".", 2)
+	roleParts := strings.SplitN(data.Id()[separator+1:], ".", 2)
+	if separator < 0 || len(userParts) != 2 || len(roleParts) != 2 {
+		return nil, fmt.Errorf("unexpected format of ID (%s), expected %s", data.Id(), format)
+	}
+	data.SetId(userRoleBindingId(userParts[0], userParts[1], Role{Db: roleParts[0], Role: roleParts[1]}))
+	return []*schema.ResourceData{data}, nil
+}
+
+/*
+the database names can not hold a dot, the user and role names can
+*/
+func userRoleBindingId(database string, userName string, role Role) string {
+	return hex.EncodeToString([]byte(database+"."+userName)) + "/" + hex.EncodeToString([]byte(role.Db+"."+role.Role))
+}
+
+func parseUserRoleBindingId(id string) (string, string, Role, error) {
+	user, role, found := strings.Cut(id, "/")
+	if !found {
+		return "", "", Role{}, fmt.Errorf("unexpected format of ID (%s)", id)
+	}
+	userName, database, err := resourceDatabaseUserParseId(user)
+	if err != nil {
+		return "", "", Role{}, err
+	}
+	roleName, roleDb, err := resourceDatabaseUserParseId(role)
+	if err != nil {
+		return "", "", Role{}, err
+	}
+	return database, userName, Role{Role: roleName, Db: roleDb}, nil
+}
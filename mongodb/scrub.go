@@ -0,0 +1,76 @@
+package mongodb
+
+import (
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"regexp"
+	"strings"
+)
+
+/*
+the command errors and the driver errors can echo a command document or a
+connection string, the values of the password fields, the pem blocks and
+the credentials of the uris are replaced before they are shown or logged
+*/
+var (
+	secretFieldPattern = regexp.MustCompile(`(?i)("?\b(?:pwd|password|passphrase|secret|token|digestPassword)"?\s*[:=]\s*)("[^"]*"|'[^']*'|[^\s,;&}\]]+)`)
+	pemBlockPattern    = regexp.MustCompile(`-----BEGIN ([A-Z0-9 ]+)-----[\s\S]*?(?:-----END [A-Z0-9 ]+-----|$)`)
+	uriCredentials     = regexp.MustCompile(`(?i)\b([a-z][a-z0-9+.-]*://)[^/@\s"']+@`)
+)
+
+const redacted = "***"
+
+func scrubSecrets(message string, secrets ...string) string {
+	for _, secret := range secrets {
+		/* short values would redact unrelated words */
+		if len(secret) >= 4 {
+			message = strings.ReplaceAll(message, secret, redacted)
+		}
+	}
+	message = pemBlockPattern.ReplaceAllString(message, "-----BEGIN $1----- "+redacted)
+	message = uriCredentials.ReplaceAllString(message, "${1}"+redacted+"@")
+	return secretFieldPattern.ReplaceAllString(message, "${1}"+redacted)
+}
+
+func scrubDiagnostics(diags diag.Diagnostics, secrets ...string) diag.Diagnostics {
+	for i := range diags {
+		diags[i].Summary = scrubSecrets(diags[i].Summary, secrets...)
+		diags[i].Detail = scrubSecrets(diags[i].Detail, secrets...)
+	}
+	return diags
+}
+
+/*
+the secrets of the provider block, the environment variables and the
+profile, the pem keys are removed by the pattern
+*/
+func providerSecrets(d *schema.ResourceData) []string {
+	secrets := []string{
+		d.Get("password").(string),
+		d.Get("key_password").(string),
+		d.Get("pkcs12_password").(string),
+		d.Get("aws_session_token").(string),
+	}
+	for ssh := expandSSHConfig(d.Get("ssh").([]interface{})); ssh != nil; ssh = ssh.Bastion {
+		secrets = append(secrets, ssh.Password, ssh.PrivateKeyPassword)
+	}
+	return secrets
+}
+
+/*
+the password of the resource, when it has one
+*/
+func resourceSecrets(data *schema.ResourceData) []string {
+	var secrets []string
+	if rawConfig := data.GetRawConfig(); setInConfig(rawConfig, "password_wo") {
+		if value := rawConfig.GetAttr("password_wo"); value.IsKnown() {
+			secrets = append(secrets, value.AsString())
+		}
+	}
+	if password, ok := data.GetOk("password"); ok {
+		if value, isString := password.(string); isString {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
@@ -0,0 +1,116 @@
+package mongodb
+
+import (
+	"context"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+	"sync"
+)
+
+var tracer = otel.Tracer("github.com/Kaginari/terraform-provider-mongodb")
+
+/*
+tracing is only turned on when an otlp endpoint is set, the exporter reads
+its other settings (headers, timeout, ...) from the OTEL_* variables too,
+the returned function flushes the spans before the provider exits
+*/
+func SetupTracing(ctx context.Context, version string) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "terraform-provider-mongodb"),
+			attribute.String("service.version", version),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	return tracerProvider.Shutdown, nil
+}
+
+/*
+one span per resource operation, the command spans are its children
+*/
+func tracedOperation(name string, operation func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics) func(context.Context, *schema.ResourceData, interface{}) diag.Diagnostics {
+	return func(ctx context.Context, data *schema.ResourceData, meta interface{}) diag.Diagnostics {
+		ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attribute.String("terraform.resource.id", data.Id())))
+		defer span.End()
+		secrets := resourceSecrets(data)
+		if providerMeta, ok := meta.(*ProviderMeta); ok && providerMeta.Config != nil {
+			secrets = append(secrets, providerMeta.Config.Password)
+		}
+		diags := scrubDiagnostics(operation(ctx, data, meta), secrets...)
+		for _, d := range diags {
+			if d.Severity == diag.Error {
+				span.SetStatus(codes.Error, d.Summary)
+				break
+			}
+		}
+		return diags
+	}
+}
+
+/*
+the command spans are ended by the succeeded and failed events, which are
+matched with the started event by request id, the command documents are
+not recorded since they hold the passwords
+*/
+type commandTracer struct {
+	spans sync.Map
+}
+
+func (t *commandTracer) monitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			_, span := tracer.Start(ctx, evt.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system", "mongodb"),
+					attribute.String("db.name", evt.DatabaseName),
+					attribute.String("db.operation", evt.CommandName),
+					attribute.String("db.mongodb.connection_id", evt.ConnectionID),
+				),
+			)
+			t.spans.Store(evt.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			t.end(evt.RequestID, "")
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			t.end(evt.RequestID, evt.Failure)
+		},
+	}
+}
+
+func (t *commandTracer) end(requestID int64, failure string) {
+	value, ok := t.spans.LoadAndDelete(requestID)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	if failure != "" {
+		span.SetStatus(codes.Error, scrubSecrets(failure))
+	}
+	span.End()
+}